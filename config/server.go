@@ -3,6 +3,8 @@ package config
 import "time"
 
 type Server struct {
+	Name           string
 	Port           int
 	RequestTimeout time.Duration
+	Debug          bool
 }
@@ -1,22 +1,59 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cetnfurkan/core/config"
+
+	clickhousego "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/mitchellh/mapstructure"
 	"gorm.io/driver/clickhouse"
 	"gorm.io/gorm"
 )
 
+const (
+	defaultClickhouseDialTimeout = 10
+	defaultClickhouseReadTimeout = 20
+)
+
 type (
 	clickhouseDatabase struct {
 		cfg    *clickhouseDatabaseConfig
 		client *gorm.DB
+
+		instrumentation *instrumentation
 	}
 
 	clickhouseDatabaseConfig struct {
 		*config.Database
+		Extra clickhouseDatabaseConfigExtra
+	}
+
+	clickhouseDatabaseConfigExtra struct {
+		Hosts              []string       `mapstructure:"hosts"`
+		ConnOpenStrategy   string         `mapstructure:"connOpenStrategy"`
+		Protocol           string         `mapstructure:"protocol"`
+		Secure             bool           `mapstructure:"secure"`
+		SkipVerify         bool           `mapstructure:"skipVerify"`
+		Compression        string         `mapstructure:"compression"`
+		CompressionLevel   int            `mapstructure:"compressionLevel"`
+		DialTimeout        int            `mapstructure:"dialTimeout"`
+		ReadTimeout        int            `mapstructure:"readTimeout"`
+		MaxOpenConns       int            `mapstructure:"maxOpenConns"`
+		MaxIdleConns       int            `mapstructure:"maxIdleConns"`
+		MaxOpenConnTTL     int            `mapstructure:"maxOpenConnTTL"`
+		MaxExecutionTime   int            `mapstructure:"maxExecutionTime"`
+		AsyncInsert        bool           `mapstructure:"asyncInsert"`
+		WaitForAsyncInsert *bool          `mapstructure:"waitForAsyncInsert"`
+		Settings           map[string]any `mapstructure:"settings"`
 	}
 )
 
@@ -24,8 +61,20 @@ type (
 //
 // It takes a config instance and returns a new database interface instance.
 //
+// Hosts in extra config data are connected in addition to the main host,
+// following connOpenStrategy (in_order or round_robin).
+//
+// With asyncInsert, inserts are buffered by clickhouse and by default
+// acknowledged once the buffer is flushed, so failed inserts are still
+// reported. Set waitForAsyncInsert to false to be acknowledged right
+// away for lower latency, at the risk of silently losing rows that
+// fail to flush.
+//
 // It will panic
-// if it fails to connect to clickhouse database.
+// if it fails to unmarhal extra config data,
+// if it fails to connect to clickhouse database or
+// if it fails to run migrations given by WithMigrations, which need
+// WithMigrationLocker since clickhouse has no advisory locks.
 func NewClickhouseDatabase(cfg *config.Database, opts ...option[gorm.DB]) Database {
 	var (
 		err error
@@ -37,34 +86,187 @@ func NewClickhouseDatabase(cfg *config.Database, opts ...option[gorm.DB]) Databa
 		},
 	}
 
-	database.client, err = gorm.Open(clickhouse.Open(database.getDSN()), &gorm.Config{})
+	database.UnmarshalExtra()
+
+	dbOptions := newOptions(opts)
+	database.instrumentation = dbOptions.instrumentation
+
+	dialector, err := database.dialector()
+	if err != nil {
+		log.Fatalf("failed to parse clickhouse dsn: %v", err)
+	}
+
+	database.client, err = gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		log.Fatalf("failed to connect to clickhouse database: %v", err)
 	}
 
-	for _, opt := range opts {
-		err = opt(database.client)
+	database.configurePool()
+
+	if dbOptions.migrations != nil {
+		pool, err := database.client.DB()
+		if err != nil {
+			log.Fatalf("failed to get clickhouse connection pool: %v", err)
+		}
+
+		err = dbOptions.migrations.run(DialectClickhouse, pool)
 		if err != nil {
-			log.Fatalf("failed to apply option: %v", err)
+			log.Fatalf("failed to run clickhouse migrations: %v", err)
 		}
 	}
 
+	dbOptions.applyCallbacks(database.client)
+
 	return database
 }
 
-func (database *clickhouseDatabase) UnmarshalExtra() {}
+func (database *clickhouseDatabase) UnmarshalExtra() {
+	err := mapstructure.Decode(database.cfg.Database.Extra, &database.cfg.Extra)
+	if err != nil {
+		log.Fatal("failed to decode extra config into struct: ", err)
+	}
+}
+
+// WithClickhouseSettings returns a context that applies the given
+// settings to the queries run with it, e.g. through gorm's WithContext.
+func WithClickhouseSettings(ctx context.Context, settings map[string]any) context.Context {
+	return clickhousego.Context(ctx, clickhousego.WithSettings(clickhousego.Settings(settings)))
+}
 
 func (database *clickhouseDatabase) Get() any {
 	return database.client
 }
 
+// DB returns the connection pool of the gorm client.
+func (database *clickhouseDatabase) DB() *sql.DB {
+	pool, err := database.client.DB()
+	if err != nil {
+		return nil
+	}
+
+	return pool
+}
+
+// dialector opens the connection pool through the instrumented
+// connector when instrumentation is enabled.
+func (database *clickhouseDatabase) dialector() (gorm.Dialector, error) {
+	dsn := database.getDSN()
+
+	if database.instrumentation == nil {
+		return clickhouse.Open(dsn), nil
+	}
+
+	connOptions, err := clickhousego.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := sql.OpenDB(database.instrumentation.wrap(clickhousego.Connector(connOptions), systemClickhouse))
+
+	return clickhouse.New(clickhouse.Config{
+		DSN:  dsn,
+		Conn: pool,
+	}), nil
+}
+
+// configurePool applies the pool sizes, the clickhouse driver
+// ignores them when it is opened through database/sql.
+func (database *clickhouseDatabase) configurePool() {
+	pool := database.DB()
+	if pool == nil {
+		return
+	}
+
+	if database.cfg.Extra.MaxOpenConns > 0 {
+		pool.SetMaxOpenConns(database.cfg.Extra.MaxOpenConns)
+	}
+
+	if database.cfg.Extra.MaxIdleConns > 0 {
+		pool.SetMaxIdleConns(database.cfg.Extra.MaxIdleConns)
+	}
+
+	if database.cfg.Extra.MaxOpenConnTTL > 0 {
+		pool.SetConnMaxLifetime(time.Duration(database.cfg.Extra.MaxOpenConnTTL) * time.Second)
+	}
+}
+
 func (database *clickhouseDatabase) getDSN() string {
-	return fmt.Sprintf(
-		"clickhouse://%s:%s@%s:%d/%s?dial_timeout=10s&read_timeout=20s",
-		database.cfg.Database.User,
-		database.cfg.Database.Password,
-		database.cfg.Database.Host,
-		database.cfg.Database.Port,
-		database.cfg.Database.Name,
+	var (
+		extra = database.cfg.Extra
+		query = make(url.Values)
+		hosts = make([]string, 0, len(extra.Hosts)+1)
 	)
+
+	if database.cfg.Host != "" {
+		hosts = append(hosts, net.JoinHostPort(database.cfg.Host, strconv.Itoa(database.cfg.Port)))
+	}
+
+	hosts = append(hosts, extra.Hosts...)
+
+	if extra.DialTimeout <= 0 {
+		extra.DialTimeout = defaultClickhouseDialTimeout
+	}
+
+	if extra.ReadTimeout <= 0 {
+		extra.ReadTimeout = defaultClickhouseReadTimeout
+	}
+
+	query.Set("dial_timeout", fmt.Sprintf("%ds", extra.DialTimeout))
+	query.Set("read_timeout", fmt.Sprintf("%ds", extra.ReadTimeout))
+
+	if extra.ConnOpenStrategy != "" {
+		query.Set("connection_open_strategy", extra.ConnOpenStrategy)
+	}
+
+	if extra.Secure {
+		query.Set("secure", "true")
+	}
+
+	if extra.SkipVerify {
+		query.Set("skip_verify", "true")
+	}
+
+	if extra.Compression != "" {
+		query.Set("compress", extra.Compression)
+	}
+
+	if extra.CompressionLevel > 0 {
+		query.Set("compress_level", strconv.Itoa(extra.CompressionLevel))
+	}
+
+	// Unknown parameters are sent to clickhouse as settings.
+	for key, value := range extra.Settings {
+		query.Set(key, fmt.Sprint(value))
+	}
+
+	if extra.MaxExecutionTime > 0 {
+		query.Set("max_execution_time", strconv.Itoa(extra.MaxExecutionTime))
+	}
+
+	if extra.AsyncInsert {
+		query.Set("async_insert", "1")
+		query.Set("wait_for_async_insert", "1")
+	}
+
+	if extra.WaitForAsyncInsert != nil && !*extra.WaitForAsyncInsert {
+		query.Set("wait_for_async_insert", "0")
+	}
+
+	scheme := "clickhouse"
+	if strings.EqualFold(extra.Protocol, "http") {
+		scheme = "http"
+		if extra.Secure {
+			scheme = "https"
+		}
+	}
+
+	dsn := url.URL{
+		Scheme:   scheme,
+		User:     url.UserPassword(database.cfg.User, database.cfg.Password),
+		Host:     strings.Join(hosts, ","),
+		Path:     database.cfg.Name,
+		RawQuery: query.Encode(),
+	}
+
+	return dsn.String()
 }
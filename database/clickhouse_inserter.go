@@ -0,0 +1,445 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// ClickhouseInserter buffers rows per table in memory and writes them
+	// to clickhouse in batches.
+	//
+	// A table is flushed when it reaches the batch size or batch bytes, and
+	// every table is flushed on the flush interval. Insert blocks while the
+	// total buffered rows are at the limit until a flush frees space.
+	// Failed batches are retried in the background so the other tables
+	// keep flushing.
+	ClickhouseInserter struct {
+		db              *sql.DB
+		batchSize       int
+		batchBytes      int
+		flushInterval   time.Duration
+		maxBufferedRows int
+		maxRetries      int
+		retryBackoff    time.Duration
+		registerer      prometheus.Registerer
+
+		mutex   sync.Mutex
+		closed  bool
+		buffers map[string]*clickhouseBatch
+		slots   chan struct{}
+		flushes chan string
+		done    chan struct{}
+		stopped chan struct{}
+		retries sync.WaitGroup
+		metrics *clickhouseInserterMetrics
+	}
+
+	clickhouseBatch struct {
+		rows  [][]any
+		bytes int
+	}
+
+	clickhouseInserterMetrics struct {
+		flushedRows    *prometheus.CounterVec
+		flushedBatches *prometheus.CounterVec
+		flushDuration  *prometheus.HistogramVec
+		bufferedRows   prometheus.Gauge
+	}
+
+	clickhouseInserterOption func(*ClickhouseInserter)
+)
+
+// WithInserterBatchSize sets the number of rows that triggers a flush
+// of a table. Default is 10000.
+func WithInserterBatchSize(size int) clickhouseInserterOption {
+	return func(inserter *ClickhouseInserter) {
+		inserter.batchSize = size
+	}
+}
+
+// WithInserterBatchBytes sets the estimated size in bytes that triggers
+// a flush of a table. Default is 16 MiB.
+func WithInserterBatchBytes(bytes int) clickhouseInserterOption {
+	return func(inserter *ClickhouseInserter) {
+		inserter.batchBytes = bytes
+	}
+}
+
+// WithInserterFlushInterval sets the interval every table is flushed on.
+// Default is 1 second.
+func WithInserterFlushInterval(interval time.Duration) clickhouseInserterOption {
+	return func(inserter *ClickhouseInserter) {
+		inserter.flushInterval = interval
+	}
+}
+
+// WithInserterMaxBufferedRows sets the total number of rows buffered
+// before Insert blocks. Default is 100000.
+func WithInserterMaxBufferedRows(rows int) clickhouseInserterOption {
+	return func(inserter *ClickhouseInserter) {
+		inserter.maxBufferedRows = rows
+	}
+}
+
+// WithInserterRetry sets how many times a failed batch is retried and
+// the base delay between retries. Default is 3 retries from 1 second.
+func WithInserterRetry(retries int, backoff time.Duration) clickhouseInserterOption {
+	return func(inserter *ClickhouseInserter) {
+		inserter.maxRetries = retries
+		inserter.retryBackoff = backoff
+	}
+}
+
+// WithInserterMetrics registers the flush metrics to the registerer.
+// Inserters of the same registerer share the metrics.
+func WithInserterMetrics(registerer prometheus.Registerer) clickhouseInserterOption {
+	return func(inserter *ClickhouseInserter) {
+		inserter.registerer = registerer
+	}
+}
+
+// NewClickhouseInserter creates a new clickhouse inserter instance.
+//
+// It takes a database created by NewClickhouseDatabase and
+// starts flushing in the background.
+//
+// It will panic
+// if the database is not backed by database/sql or
+// if it fails to register the metrics.
+func NewClickhouseInserter(database Database, opts ...clickhouseInserterOption) *ClickhouseInserter {
+	db, err := GetDB(database)
+	if err != nil {
+		log.Fatal("failed to get clickhouse connection pool: ", err)
+	}
+
+	inserter := &ClickhouseInserter{
+		db:              db,
+		batchSize:       10000,
+		batchBytes:      16 << 20,
+		flushInterval:   time.Second,
+		maxBufferedRows: 100000,
+		maxRetries:      3,
+		retryBackoff:    time.Second,
+		buffers:         make(map[string]*clickhouseBatch),
+		flushes:         make(chan string, 64),
+		done:            make(chan struct{}),
+		stopped:         make(chan struct{}),
+		metrics:         newClickhouseInserterMetrics(),
+	}
+
+	for _, opt := range opts {
+		opt(inserter)
+	}
+
+	if inserter.registerer != nil {
+		err = inserter.metrics.register(inserter.registerer)
+		if err != nil {
+			log.Fatal("failed to register clickhouse inserter metrics: ", err)
+		}
+	}
+
+	inserter.slots = make(chan struct{}, inserter.maxBufferedRows)
+
+	go inserter.run()
+
+	return inserter
+}
+
+// Insert buffers a row for the table. The table may include a column
+// list, e.g. "events (id, name)", and the values must follow its order.
+//
+// It blocks while the buffer is full and returns an error
+// if the context is done first or if the inserter is closed.
+func (inserter *ClickhouseInserter) Insert(ctx context.Context, table string, values ...any) error {
+	select {
+	case inserter.slots <- struct{}{}:
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	inserter.mutex.Lock()
+
+	if inserter.closed {
+		inserter.mutex.Unlock()
+		<-inserter.slots
+		return coreErrors.ErrInserterClosed
+	}
+
+	batch, ok := inserter.buffers[table]
+	if !ok {
+		batch = &clickhouseBatch{}
+		inserter.buffers[table] = batch
+	}
+
+	batch.rows = append(batch.rows, values)
+	batch.bytes += estimateRowSize(values)
+	full := len(batch.rows) >= inserter.batchSize || batch.bytes >= inserter.batchBytes
+
+	inserter.mutex.Unlock()
+
+	inserter.metrics.bufferedRows.Inc()
+
+	if full {
+		// The flush interval picks the table up if the queue is busy.
+		select {
+		case inserter.flushes <- table:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Close stops accepting rows and flushes every buffered table.
+// It returns an error if the context is done before the flush completes.
+func (inserter *ClickhouseInserter) Close(ctx context.Context) error {
+	inserter.mutex.Lock()
+	if !inserter.closed {
+		inserter.closed = true
+		close(inserter.done)
+	}
+	inserter.mutex.Unlock()
+
+	select {
+	case <-inserter.stopped:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (inserter *ClickhouseInserter) run() {
+	defer close(inserter.stopped)
+	defer inserter.retries.Wait()
+
+	ticker := time.NewTicker(inserter.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case table := <-inserter.flushes:
+			inserter.flush(table)
+
+		case <-ticker.C:
+			inserter.flushAll()
+
+		case <-inserter.done:
+			inserter.flushAll()
+			return
+		}
+	}
+}
+
+func (inserter *ClickhouseInserter) flushAll() {
+	inserter.mutex.Lock()
+	tables := make([]string, 0, len(inserter.buffers))
+	for table := range inserter.buffers {
+		tables = append(tables, table)
+	}
+	inserter.mutex.Unlock()
+
+	for _, table := range tables {
+		inserter.flush(table)
+	}
+}
+
+func (inserter *ClickhouseInserter) flush(table string) {
+	inserter.mutex.Lock()
+	batch, ok := inserter.buffers[table]
+	delete(inserter.buffers, table)
+	inserter.mutex.Unlock()
+
+	if !ok || len(batch.rows) == 0 {
+		return
+	}
+
+	err := inserter.flushBatch(table, batch)
+	if err != nil {
+		inserter.retries.Add(1)
+		go inserter.retry(table, batch, err)
+		return
+	}
+
+	inserter.release(batch)
+}
+
+// retry writes the batch again with an exponential backoff and drops it
+// once the retries are used up. Closing the inserter skips the backoff
+// and gives the batch a last try.
+func (inserter *ClickhouseInserter) retry(table string, batch *clickhouseBatch, err error) {
+	defer inserter.retries.Done()
+	defer inserter.release(batch)
+
+	for attempt := 1; attempt <= inserter.maxRetries; attempt++ {
+		timer := time.NewTimer(inserter.retryBackoff * time.Duration(1<<(attempt-1)))
+
+		select {
+		case <-timer.C:
+
+		case <-inserter.done:
+			timer.Stop()
+			attempt = inserter.maxRetries
+		}
+
+		err = inserter.flushBatch(table, batch)
+		if err == nil {
+			return
+		}
+	}
+
+	log.Printf("clickhouse inserter dropped %d rows of %s: %v", len(batch.rows), table, err)
+}
+
+// flushBatch writes the batch once and records the metrics of the write.
+func (inserter *ClickhouseInserter) flushBatch(table string, batch *clickhouseBatch) error {
+	start := time.Now()
+	err := inserter.write(table, batch.rows)
+	inserter.metrics.flushDuration.WithLabelValues(table).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		inserter.metrics.flushedBatches.WithLabelValues(table, "failure").Inc()
+		return err
+	}
+
+	inserter.metrics.flushedBatches.WithLabelValues(table, "success").Inc()
+	inserter.metrics.flushedRows.WithLabelValues(table).Add(float64(len(batch.rows)))
+
+	return nil
+}
+
+// release frees the buffer space of a written or dropped batch.
+func (inserter *ClickhouseInserter) release(batch *clickhouseBatch) {
+	for range batch.rows {
+		<-inserter.slots
+	}
+
+	inserter.metrics.bufferedRows.Sub(float64(len(batch.rows)))
+}
+
+// write sends the rows as a single native block. The clickhouse driver
+// batches the statements of a transaction until it is committed.
+func (inserter *ClickhouseInserter) write(table string, rows [][]any) error {
+	ctx := context.Background()
+
+	tx, err := inserter.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin clickhouse batch")
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s", table))
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare clickhouse batch")
+	}
+
+	defer stmt.Close()
+
+	for _, row := range rows {
+		_, err = stmt.ExecContext(ctx, row...)
+		if err != nil {
+			return errors.Wrap(err, "failed to append row to clickhouse batch")
+		}
+	}
+
+	return tx.Commit()
+}
+
+// estimateRowSize approximates the size of a row for the byte threshold.
+func estimateRowSize(values []any) int {
+	size := 0
+
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			size += len(v)
+
+		case []byte:
+			size += len(v)
+
+		case nil:
+			size++
+
+		default:
+			size += 8
+		}
+	}
+
+	return size
+}
+
+func newClickhouseInserterMetrics() *clickhouseInserterMetrics {
+	return &clickhouseInserterMetrics{
+		flushedRows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "clickhouse_inserter_flushed_rows_total",
+			Help: "Number of rows written by the clickhouse inserter.",
+		}, []string{"table"}),
+		flushedBatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "clickhouse_inserter_flushed_batches_total",
+			Help: "Number of batch writes by the clickhouse inserter.",
+		}, []string{"table", "result"}),
+		flushDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "clickhouse_inserter_flush_duration_seconds",
+			Help:    "Duration of batch writes by the clickhouse inserter.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"table"}),
+		bufferedRows: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "clickhouse_inserter_buffered_rows",
+			Help: "Number of rows waiting to be flushed by the clickhouse inserter.",
+		}),
+	}
+}
+
+func (metrics *clickhouseInserterMetrics) register(registerer prometheus.Registerer) error {
+	err := registerCollector(registerer, &metrics.flushedRows)
+	if err != nil {
+		return err
+	}
+
+	err = registerCollector(registerer, &metrics.flushedBatches)
+	if err != nil {
+		return err
+	}
+
+	err = registerCollector(registerer, &metrics.flushDuration)
+	if err != nil {
+		return err
+	}
+
+	return registerCollector(registerer, &metrics.bufferedRows)
+}
+
+// registerCollector registers the collector, or replaces it with the
+// collector registered before under the same name.
+func registerCollector[C prometheus.Collector](registerer prometheus.Registerer, collector *C) error {
+	err := registerer.Register(*collector)
+	if err == nil {
+		return nil
+	}
+
+	registered, ok := err.(prometheus.AlreadyRegisteredError)
+	if !ok {
+		return err
+	}
+
+	existing, ok := registered.ExistingCollector.(C)
+	if !ok {
+		return err
+	}
+
+	*collector = existing
+
+	return nil
+}
@@ -1,5 +1,12 @@
 package database
 
+import (
+	"database/sql"
+	"log"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+)
+
 // Database is an interface for databases like postgres, mysql, etc.
 type Database interface {
 	// Get returns the database client instance.
@@ -10,10 +17,90 @@ type Database interface {
 	UnmarshalExtra()
 }
 
-type option[T any] func(*T) error
+type (
+	sqlDatabase interface {
+		DB() *sql.DB
+	}
+
+	closer interface {
+		Close() error
+	}
+
+	option[T any] func(*options[T]) error
+
+	options[T any] struct {
+		callbacks       []func(*T) error
+		migrations      *migrationOptions
+		instrumentation *instrumentation
+		tenantName      func(tenant string) (string, error)
+	}
+)
+
+// GetDB returns the connection pool of the given database.
+// It returns an error if the database is not backed by database/sql.
+func GetDB(database Database) (*sql.DB, error) {
+	sqlDB, ok := database.(sqlDatabase)
+	if !ok || sqlDB.DB() == nil {
+		return nil, coreErrors.ErrSQLDBNotFound
+	}
+
+	return sqlDB.DB(), nil
+}
+
+// Close closes the pools and stops the background workers of the given
+// database, e.g. the idle tenant eviction. Databases without any are
+// left as is.
+func Close(database Database) error {
+	closer, ok := database.(closer)
+	if !ok {
+		return nil
+	}
+
+	return closer.Close()
+}
 
 func WithCallback[T any](callback func(*T) error) option[T] {
-	return func(client *T) error {
-		return callback(client)
+	return func(opts *options[T]) error {
+		opts.callbacks = append(opts.callbacks, callback)
+		return nil
+	}
+}
+
+// newOptions collects the given options into a single options instance.
+//
+// It will panic if any of the options fails to apply.
+func newOptions[T any](opts []option[T]) *options[T] {
+	collected := &options[T]{}
+
+	for _, opt := range opts {
+		err := opt(collected)
+		if err != nil {
+			log.Fatalf("failed to apply option: %v", err)
+		}
+	}
+
+	return collected
+}
+
+// applyCallbacks runs the registered callbacks against the client.
+//
+// It will panic if any of the callbacks returns an error.
+func (opts *options[T]) applyCallbacks(client *T) {
+	err := opts.runCallbacks(client)
+	if err != nil {
+		log.Fatalf("failed to apply option: %v", err)
 	}
 }
+
+// runCallbacks runs the registered callbacks against the client
+// and returns the first error.
+func (opts *options[T]) runCallbacks(client *T) error {
+	for _, callback := range opts.callbacks {
+		err := callback(client)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,514 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"entgo.io/ent"
+	entsql "entgo.io/ent/dialect/sql"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/mixin"
+	"github.com/pkg/errors"
+)
+
+const (
+	AuditTable = "audit_log"
+
+	CreatedByField = "created_by"
+	UpdatedByField = "updated_by"
+)
+
+// AuditSchema creates the postgres table written by AuditTableSink.
+var AuditSchema = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (
+	id          BIGSERIAL PRIMARY KEY,
+	entity_type TEXT NOT NULL,
+	entity_id   TEXT,
+	op          TEXT NOT NULL,
+	principal   TEXT,
+	before      JSONB,
+	after       JSONB,
+	cleared     JSONB,
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS %[1]s_entity_idx ON %[1]s (entity_type, entity_id);`, AuditTable)
+
+type (
+	// AuditMixin adds created_by and updated_by fields to an ent schema.
+	// They are filled from the principal of the context by AuditCallback.
+	AuditMixin struct {
+		mixin.Schema
+	}
+
+	// AuditEntry describes a change made by a mutation.
+	//
+	// Before holds the old values of the changed fields of single row
+	// updates, and every field of the deleted row of single row deletes.
+	// After is empty for deletes. Bulk updates and deletes have an entry
+	// per affected row, without Before.
+	AuditEntry struct {
+		EntityType string         `json:"entity_type"`
+		EntityID   string         `json:"entity_id,omitempty"`
+		Op         string         `json:"op"`
+		Principal  string         `json:"principal,omitempty"`
+		Before     map[string]any `json:"before,omitempty"`
+		After      map[string]any `json:"after,omitempty"`
+		Cleared    []string       `json:"cleared,omitempty"`
+		At         time.Time      `json:"at"`
+	}
+
+	// AuditSink stores the audit entries.
+	//
+	// The execer is the ent client of the mutation, bound to its
+	// transaction if it has one, so entries written through it are
+	// rolled back with the mutation. It is nil if the client is
+	// generated without the sql/execquery feature.
+	AuditSink interface {
+		Write(ctx context.Context, execer Execer, entry *AuditEntry) error
+	}
+
+	// AuditSinkFunc is an adapter to use a function as an AuditSink.
+	AuditSinkFunc func(ctx context.Context, execer Execer, entry *AuditEntry) error
+
+	audit struct {
+		sinks    []AuditSink
+		types    map[string]bool
+		redacted map[string]bool
+	}
+
+	auditOption func(*audit)
+
+	principalContextKey struct{}
+
+	// hookUser is implemented by the generated ent clients.
+	hookUser interface {
+		Use(hooks ...ent.Hook)
+	}
+
+	tableAuditSink struct {
+		dialect string
+	}
+)
+
+// WithPrincipal returns a context that carries the principal
+// making the changes, e.g. the user id.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal carried by the context.
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(string)
+	return principal, ok && principal != ""
+}
+
+func (AuditMixin) Fields() []ent.Field {
+	return []ent.Field{
+		field.String(CreatedByField).
+			Optional().
+			Immutable(),
+		field.String(UpdatedByField).
+			Optional(),
+	}
+}
+
+func (f AuditSinkFunc) Write(ctx context.Context, execer Execer, entry *AuditEntry) error {
+	return f(ctx, execer, entry)
+}
+
+// WithAuditSink adds a sink the audit entries are written to.
+func WithAuditSink(sink AuditSink) auditOption {
+	return func(audit *audit) {
+		audit.sinks = append(audit.sinks, sink)
+	}
+}
+
+// WithAuditTypes limits the audit entries to the given ent types, e.g. "User".
+// By default every type is audited.
+func WithAuditTypes(types ...string) auditOption {
+	return func(audit *audit) {
+		for _, entityType := range types {
+			audit.types[entityType] = true
+		}
+	}
+}
+
+// WithAuditRedactedFields hides the values of the given fields,
+// e.g. "password", in the audit entries.
+func WithAuditRedactedFields(fields ...string) auditOption {
+	return func(audit *audit) {
+		for _, name := range fields {
+			audit.redacted[name] = true
+		}
+	}
+}
+
+// AuditTableSink writes the audit entries into the audit table through
+// the client of the mutation, see AuditSchema. The ent client must be
+// generated with the sql/execquery feature.
+//
+// It takes the dialect of the client (DialectPostgres, DialectMySQL or
+// DialectSQLite) the insert is built for.
+//
+// Use outbox.AuditSink to publish the entries to an mq producer.
+func AuditTableSink(dialectName string) AuditSink {
+	return &tableAuditSink{dialect: dialectName}
+}
+
+// AuditCallback returns a callback for WithCallback that installs the
+// audit hook on an ent client.
+//
+// The hook fills created_by and updated_by of the schemas using AuditMixin
+// from the principal of the context, and writes an entry for each row
+// changed by a successful mutation to the sinks. Single row deletes load
+// the row and bulk changes load the ids of the rows before they run.
+//
+// Entries are only atomic with the mutation when it runs in an ent
+// transaction: they are written in it, and a failed write fails the
+// mutation so the transaction is rolled back. Without a transaction the
+// change is committed before the entries are written, so a failed write
+// is logged and the change is kept without its entry.
+func AuditCallback[T any](opts ...auditOption) func(*T) error {
+	audit := &audit{
+		types:    make(map[string]bool),
+		redacted: make(map[string]bool),
+	}
+
+	for _, opt := range opts {
+		opt(audit)
+	}
+
+	return func(client *T) error {
+		user, ok := any(client).(hookUser)
+		if !ok {
+			return coreErrors.ErrHooksNotSupported
+		}
+
+		user.Use(audit.hook)
+		return nil
+	}
+}
+
+func (audit *audit) hook(next ent.Mutator) ent.Mutator {
+	return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+		principal, _ := PrincipalFromContext(ctx)
+
+		// Setting a field fails on schemas without AuditMixin, which is fine.
+		if principal != "" && !m.Op().Is(ent.OpDelete|ent.OpDeleteOne) {
+			if m.Op().Is(ent.OpCreate) {
+				_ = m.SetField(CreatedByField, principal)
+			}
+
+			_ = m.SetField(UpdatedByField, principal)
+		}
+
+		if len(audit.sinks) == 0 || (len(audit.types) > 0 && !audit.types[m.Type()]) {
+			return next.Mutate(ctx, m)
+		}
+
+		op := m.Op()
+
+		entries, err := audit.entries(ctx, m, principal)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := next.Mutate(ctx, m)
+		if err != nil {
+			return value, err
+		}
+
+		// A soft delete reruns the mutation as an update, which is audited itself.
+		if m.Op() != op {
+			return value, nil
+		}
+
+		// Created rows only have an id after the mutation.
+		if entries[0].EntityID == "" && m.Op().Is(ent.OpCreate) {
+			if id, ok := entityID(value); ok {
+				entries[0].EntityID = fmt.Sprint(id)
+			}
+		}
+
+		execer, _ := mutationExecer(m)
+		inTx := mutationInTx(m)
+
+		for _, entry := range entries {
+			for _, sink := range audit.sinks {
+				err := sink.Write(ctx, execer, entry)
+				if err == nil {
+					continue
+				}
+
+				err = errors.Wrapf(err, "failed to write audit entry of %s", entry.EntityType)
+				if inTx {
+					return nil, err
+				}
+
+				log.Printf("%v, the change is committed without it", err)
+			}
+		}
+
+		return value, nil
+	})
+}
+
+// entries returns the entries of the mutation before it runs, while the
+// old values and the ids of the affected rows can still be loaded.
+// Bulk updates and deletes have an entry per affected row.
+func (audit *audit) entries(ctx context.Context, m ent.Mutation, principal string) ([]*AuditEntry, error) {
+	var after map[string]any
+
+	if !m.Op().Is(ent.OpDelete | ent.OpDeleteOne) {
+		after = make(map[string]any)
+	}
+
+	for _, name := range m.Fields() {
+		after[name], _ = m.Field(name)
+	}
+
+	for _, name := range m.AddedFields() {
+		after[name], _ = m.AddedField(name)
+	}
+
+	audit.redact(after)
+
+	newEntry := func(id any) *AuditEntry {
+		entry := &AuditEntry{
+			EntityType: m.Type(),
+			Op:         m.Op().String(),
+			Principal:  principal,
+			After:      after,
+			Cleared:    m.ClearedFields(),
+			At:         time.Now(),
+		}
+
+		if id != nil {
+			entry.EntityID = fmt.Sprint(id)
+		}
+
+		return entry
+	}
+
+	switch {
+	case m.Op().Is(ent.OpCreate):
+		id, _ := mutationID(m)
+		return []*AuditEntry{newEntry(id)}, nil
+
+	case m.Op().Is(ent.OpUpdateOne):
+		id, _ := mutationID(m)
+		entry := newEntry(id)
+		entry.Before = make(map[string]any)
+
+		for _, name := range append(m.Fields(), m.ClearedFields()...) {
+			value, err := m.OldField(ctx, name)
+			if err == nil {
+				entry.Before[name] = value
+			}
+		}
+
+		audit.redact(entry.Before)
+
+		return []*AuditEntry{entry}, nil
+
+	case m.Op().Is(ent.OpDeleteOne):
+		id, _ := mutationID(m)
+		entry := newEntry(id)
+
+		before, err := loadEntity(ctx, m, id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load audited %s", m.Type())
+		}
+
+		entry.Before = before
+		audit.redact(entry.Before)
+
+		return []*AuditEntry{entry}, nil
+
+	default:
+		ids, err := mutationIDs(ctx, m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load ids of audited %s", m.Type())
+		}
+
+		entries := make([]*AuditEntry, len(ids))
+		for i, id := range ids {
+			entries[i] = newEntry(id)
+		}
+
+		return entries, nil
+	}
+}
+
+func (audit *audit) redact(values map[string]any) {
+	for name := range values {
+		if audit.redacted[name] {
+			values[name] = "<redacted>"
+		}
+	}
+}
+
+func (sink *tableAuditSink) Write(ctx context.Context, execer Execer, entry *AuditEntry) error {
+	if execer == nil {
+		return coreErrors.ErrExecQueryNotSupported
+	}
+
+	before, err := marshalAuditValue(entry.Before)
+	if err != nil {
+		return err
+	}
+
+	after, err := marshalAuditValue(entry.After)
+	if err != nil {
+		return err
+	}
+
+	cleared, err := marshalAuditValue(entry.Cleared)
+	if err != nil {
+		return err
+	}
+
+	query, args := entsql.Dialect(sink.dialect).
+		Insert(AuditTable).
+		Columns("entity_type", "entity_id", "op", "principal", "before", "after", "cleared", "created_at").
+		Values(entry.EntityType, entry.EntityID, entry.Op, entry.Principal, before, after, cleared, entry.At).
+		Query()
+
+	_, err = execer.ExecContext(ctx, query, args...)
+
+	return err
+}
+
+// marshalAuditValue encodes the value as a JSON string, empty values are
+// stored as NULL. Strings are accepted by the JSON columns of every dialect.
+func marshalAuditValue(value any) (any, error) {
+	if reflect.ValueOf(value).Len() == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// mutationID returns the id of a single row mutation.
+// The generated id types are not known here, so it uses reflection.
+func mutationID(m ent.Mutation) (any, bool) {
+	method := reflect.ValueOf(m).MethodByName("ID")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 2 {
+		return nil, false
+	}
+
+	results := method.Call(nil)
+	if exists, _ := results[1].Interface().(bool); !exists {
+		return nil, false
+	}
+
+	return results[0].Interface(), true
+}
+
+// mutationExecer returns the client of the mutation, which runs in its
+// transaction. The generated client types are not known here, so it uses
+// reflection.
+func mutationExecer(m ent.Mutation) (Execer, bool) {
+	method := reflect.ValueOf(m).MethodByName("Client")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return nil, false
+	}
+
+	execer, ok := method.Call(nil)[0].Interface().(Execer)
+	return execer, ok
+}
+
+// mutationIDs returns the ids of the rows a mutation changes.
+func mutationIDs(ctx context.Context, m ent.Mutation) ([]any, error) {
+	method := reflect.ValueOf(m).MethodByName("IDs")
+	if !method.IsValid() || method.Type().NumIn() != 1 || method.Type().NumOut() != 2 {
+		return nil, coreErrors.ErrHooksNotSupported
+	}
+
+	results := method.Call([]reflect.Value{reflect.ValueOf(ctx)})
+	if err, _ := results[1].Interface().(error); err != nil {
+		return nil, err
+	}
+
+	ids := make([]any, results[0].Len())
+	for i := range ids {
+		ids[i] = results[0].Index(i).Interface()
+	}
+
+	return ids, nil
+}
+
+// loadEntity loads the entity of the mutation by its id with the Get
+// method of the client of its type, and returns its fields by json name.
+func loadEntity(ctx context.Context, m ent.Mutation, id any) (map[string]any, error) {
+	client := reflect.ValueOf(m).MethodByName("Client")
+	if !client.IsValid() || client.Type().NumIn() != 0 || client.Type().NumOut() != 1 || id == nil {
+		return nil, coreErrors.ErrHooksNotSupported
+	}
+
+	typeClient := reflect.Indirect(client.Call(nil)[0]).FieldByName(m.Type())
+	if !typeClient.IsValid() {
+		return nil, coreErrors.ErrHooksNotSupported
+	}
+
+	get := typeClient.MethodByName("Get")
+	if !get.IsValid() || get.Type().NumIn() != 2 || get.Type().NumOut() != 2 {
+		return nil, coreErrors.ErrHooksNotSupported
+	}
+
+	results := get.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(id)})
+	if err, _ := results[1].Interface().(error); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(results[0].Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(fields, "edges")
+
+	return fields, nil
+}
+
+// mutationInTx reports whether the mutation runs in an ent transaction.
+func mutationInTx(m ent.Mutation) bool {
+	method := reflect.ValueOf(m).MethodByName("Tx")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 2 {
+		return false
+	}
+
+	return method.Call(nil)[1].IsNil()
+}
+
+// entityID returns the ID field of a created entity.
+func entityID(value ent.Value) (any, bool) {
+	entity := reflect.Indirect(reflect.ValueOf(value))
+	if entity.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	id := entity.FieldByName("ID")
+	if !id.IsValid() {
+		return nil, false
+	}
+
+	return id.Interface(), true
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"entgo.io/ent"
+	entsql "entgo.io/ent/dialect/sql"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/mixin"
+)
+
+const (
+	SoftDeleteField = "deleted_at"
+)
+
+type (
+	// SoftDeleteMixin adds a deleted_at field to an ent schema.
+	//
+	// Deletes of the schema set deleted_at instead of removing the rows,
+	// and queries skip the rows with deleted_at set.
+	// Use SkipSoftDelete to see deleted rows or to delete them for good.
+	//
+	// As with other schema hooks, the generated runtime package must be imported.
+	SoftDeleteMixin struct {
+		mixin.Schema
+	}
+
+	softDeleteContextKey struct{}
+
+	// wherePredicater is implemented by the generated mutations, and by
+	// the generated queries with the intercept feature.
+	wherePredicater interface {
+		WhereP(ps ...func(*entsql.Selector))
+	}
+
+	softDeleteMutation interface {
+		ent.Mutation
+		wherePredicater
+		SetOp(op ent.Op)
+	}
+)
+
+// SkipSoftDelete returns a context that includes deleted rows
+// in queries and deletes rows for good.
+func SkipSoftDelete(ctx context.Context) context.Context {
+	return context.WithValue(ctx, softDeleteContextKey{}, true)
+}
+
+func isSoftDeleteSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(softDeleteContextKey{}).(bool)
+	return skip
+}
+
+func (SoftDeleteMixin) Fields() []ent.Field {
+	return []ent.Field{
+		field.Time(SoftDeleteField).
+			Optional().
+			Nillable(),
+	}
+}
+
+func (SoftDeleteMixin) Interceptors() []ent.Interceptor {
+	return []ent.Interceptor{
+		ent.TraverseFunc(func(ctx context.Context, query ent.Query) error {
+			if isSoftDeleteSkipped(ctx) {
+				return nil
+			}
+
+			return queryWhere(query, entsql.FieldIsNull(SoftDeleteField))
+		}),
+	}
+}
+
+func (SoftDeleteMixin) Hooks() []ent.Hook {
+	return []ent.Hook{
+		func(next ent.Mutator) ent.Mutator {
+			return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+				if !m.Op().Is(ent.OpDelete|ent.OpDeleteOne) || isSoftDeleteSkipped(ctx) {
+					return next.Mutate(ctx, m)
+				}
+
+				mutation, ok := m.(softDeleteMutation)
+				if !ok {
+					return nil, coreErrors.ErrHooksNotSupported
+				}
+
+				mutation.WhereP(entsql.FieldIsNull(SoftDeleteField))
+				mutation.SetOp(ent.OpUpdate)
+
+				err := mutation.SetField(SoftDeleteField, time.Now())
+				if err != nil {
+					return nil, err
+				}
+
+				return mutateWithClient(ctx, mutation)
+			})
+		},
+	}
+}
+
+// queryWhere adds the predicate to the query. Generated queries only have
+// WhereP with the intercept feature, otherwise the predicate is converted to
+// the typed predicate of the query, so it uses reflection.
+func queryWhere(query ent.Query, predicate func(*entsql.Selector)) error {
+	if predicater, ok := query.(wherePredicater); ok {
+		predicater.WhereP(predicate)
+		return nil
+	}
+
+	where := reflect.ValueOf(query).MethodByName("Where")
+	if !where.IsValid() || !where.Type().IsVariadic() || where.Type().NumIn() != 1 {
+		return coreErrors.ErrHooksNotSupported
+	}
+
+	predicateType := where.Type().In(0).Elem()
+	if !reflect.TypeOf(predicate).ConvertibleTo(predicateType) {
+		return coreErrors.ErrHooksNotSupported
+	}
+
+	where.Call([]reflect.Value{reflect.ValueOf(predicate).Convert(predicateType)})
+	return nil
+}
+
+// mutateWithClient runs the mutation through the client of the mutation,
+// so a delete turned into an update is executed as an update.
+// The generated client types are not known here, so it uses reflection.
+func mutateWithClient(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+	client := reflect.ValueOf(m).MethodByName("Client")
+	if !client.IsValid() {
+		return nil, coreErrors.ErrHooksNotSupported
+	}
+
+	mutate := client.Call(nil)[0].MethodByName("Mutate")
+	if !mutate.IsValid() {
+		return nil, coreErrors.ErrHooksNotSupported
+	}
+
+	results := mutate.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(m)})
+
+	value, _ := results[0].Interface().(ent.Value)
+	err, _ := results[1].Interface().(error)
+
+	return value, err
+}
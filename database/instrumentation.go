@@ -0,0 +1,480 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/cetnfurkan/core/database"
+
+	systemPostgres   = "postgresql"
+	systemMySQL      = "mysql"
+	systemSQLite     = "sqlite"
+	systemClickhouse = "clickhouse"
+)
+
+type (
+	// instrumentation logs, traces and measures the queries
+	// that go through an instrumented connector.
+	instrumentation struct {
+		logQueries     bool
+		slowQuery      time.Duration
+		redact         func(args []any) []any
+		tracerProvider trace.TracerProvider
+		tracer         trace.Tracer
+		registerer     prometheus.Registerer
+		duration       *prometheus.HistogramVec
+	}
+
+	instrumentationOption func(*instrumentation)
+
+	instrumentedConnector struct {
+		connector       driver.Connector
+		system          string
+		instrumentation *instrumentation
+	}
+
+	instrumentedConn struct {
+		conn            driver.Conn
+		system          string
+		instrumentation *instrumentation
+
+		// batches are the queries of the clickhouse batches
+		// prepared in the transaction of the connection.
+		batches []string
+	}
+
+	// instrumentedStmt observes each execution, except for the rows
+	// appended to a clickhouse batch, which is observed on commit.
+	instrumentedStmt struct {
+		stmt  driver.Stmt
+		query string
+		conn  *instrumentedConn
+		batch bool
+	}
+
+	instrumentedTx struct {
+		ctx  context.Context
+		tx   driver.Tx
+		conn *instrumentedConn
+	}
+)
+
+// WithInstrumentation wraps the database driver to log, trace and
+// measure every query.
+//
+// Slow queries are logged even if query logging is disabled.
+// Spans are created from the global tracer provider unless
+// WithTracerProvider is given.
+func WithInstrumentation[T any](opts ...instrumentationOption) option[T] {
+	return func(o *options[T]) error {
+		instrumentation := &instrumentation{
+			slowQuery:      time.Second,
+			redact:         redactArgs,
+			tracerProvider: otel.GetTracerProvider(),
+		}
+
+		for _, opt := range opts {
+			opt(instrumentation)
+		}
+
+		err := instrumentation.init()
+		if err != nil {
+			return err
+		}
+
+		o.instrumentation = instrumentation
+		return nil
+	}
+}
+
+// WithQueryLogging logs every query with its duration and redacted args.
+func WithQueryLogging() instrumentationOption {
+	return func(instrumentation *instrumentation) {
+		instrumentation.logQueries = true
+	}
+}
+
+// WithSlowQueryThreshold sets the duration after which a query is
+// logged as slow. Default is 1 second, zero disables it.
+func WithSlowQueryThreshold(threshold time.Duration) instrumentationOption {
+	return func(instrumentation *instrumentation) {
+		instrumentation.slowQuery = threshold
+	}
+}
+
+// WithArgsRedactor sets how query args are shown in logs.
+// By default only the arg types are logged.
+func WithArgsRedactor(redact func(args []any) []any) instrumentationOption {
+	return func(instrumentation *instrumentation) {
+		instrumentation.redact = redact
+	}
+}
+
+// WithTracerProvider sets the provider the query spans are created with.
+func WithTracerProvider(provider trace.TracerProvider) instrumentationOption {
+	return func(instrumentation *instrumentation) {
+		instrumentation.tracerProvider = provider
+	}
+}
+
+// WithQueryMetrics registers the query duration histogram to the registerer.
+func WithQueryMetrics(registerer prometheus.Registerer) instrumentationOption {
+	return func(instrumentation *instrumentation) {
+		instrumentation.registerer = registerer
+	}
+}
+
+func (instrumentation *instrumentation) init() error {
+	instrumentation.tracer = instrumentation.tracerProvider.Tracer(instrumentationName)
+
+	instrumentation.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Duration of database queries.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"system", "operation"})
+
+	if instrumentation.registerer == nil {
+		return nil
+	}
+
+	err := instrumentation.registerer.Register(instrumentation.duration)
+	if err != nil {
+		// Databases in the same service share the histogram.
+		registered, ok := err.(prometheus.AlreadyRegisteredError)
+		if !ok {
+			return err
+		}
+
+		instrumentation.duration = registered.ExistingCollector.(*prometheus.HistogramVec)
+	}
+
+	return nil
+}
+
+// wrap returns a connector that instruments the given connector.
+// It returns the connector as is if instrumentation is not enabled.
+func (instrumentation *instrumentation) wrap(connector driver.Connector, system string) driver.Connector {
+	if instrumentation == nil {
+		return connector
+	}
+
+	return &instrumentedConnector{
+		connector:       connector,
+		system:          system,
+		instrumentation: instrumentation,
+	}
+}
+
+func (instrumentation *instrumentation) observe(ctx context.Context, system, query string, args []driver.NamedValue, run func(ctx context.Context) error) error {
+	operation := queryOperation(query)
+
+	ctx, span := instrumentation.tracer.Start(
+		ctx,
+		operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", system),
+			attribute.String("db.statement", query),
+			attribute.String("db.operation", operation),
+		),
+	)
+
+	start := time.Now()
+	err := run(ctx)
+	latency := time.Since(start)
+
+	if err != nil && err != driver.ErrSkip {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+
+	if err == driver.ErrSkip {
+		return err
+	}
+
+	instrumentation.duration.WithLabelValues(system, operation).Observe(latency.Seconds())
+
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	switch {
+	case instrumentation.slowQuery > 0 && latency >= instrumentation.slowQuery:
+		log.Printf("[SLOW QUERY] %s took: %s args: %v err: %v", query, latency, instrumentation.redact(values), err)
+
+	case instrumentation.logQueries:
+		log.Printf("[QUERY] %s took: %s args: %v err: %v", query, latency, instrumentation.redact(values), err)
+	}
+
+	return err
+}
+
+// queryOperation returns the first keyword of the query, e.g. SELECT.
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "UNKNOWN"
+	}
+
+	return strings.ToUpper(fields[0])
+}
+
+// redactArgs replaces the args with their types.
+func redactArgs(args []any) []any {
+	redacted := make([]any, len(args))
+
+	for i, arg := range args {
+		redacted[i] = fmt.Sprintf("<%T>", arg)
+	}
+
+	return redacted
+}
+
+func (connector *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := connector.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentedConn{
+		conn:            conn,
+		system:          connector.system,
+		instrumentation: connector.instrumentation,
+	}, nil
+}
+
+func (connector *instrumentedConnector) Driver() driver.Driver {
+	return connector.connector.Driver()
+}
+
+func (conn *instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	return conn.PrepareContext(context.Background(), query)
+}
+
+func (conn *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+
+	if preparer, ok := conn.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = conn.conn.Prepare(query)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The clickhouse driver prepares inserts as batches that are sent on commit.
+	batch := conn.system == systemClickhouse && queryOperation(query) == "INSERT"
+	if batch {
+		conn.batches = append(conn.batches, query)
+	}
+
+	return &instrumentedStmt{stmt: stmt, query: query, conn: conn, batch: batch}, nil
+}
+
+func (conn *instrumentedConn) Close() error {
+	return conn.conn.Close()
+}
+
+func (conn *instrumentedConn) Begin() (driver.Tx, error) {
+	return conn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (conn *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var (
+		tx  driver.Tx
+		err error
+	)
+
+	if beginner, ok := conn.conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = conn.conn.Begin()
+	}
+
+	if err != nil || conn.system != systemClickhouse {
+		return tx, err
+	}
+
+	conn.batches = nil
+
+	return &instrumentedTx{ctx: ctx, tx: tx, conn: conn}, nil
+}
+
+func (conn *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := conn.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	var result driver.Result
+
+	err := conn.instrumentation.observe(ctx, conn.system, query, args, func(ctx context.Context) (err error) {
+		result, err = execer.ExecContext(ctx, query, args)
+		return err
+	})
+
+	return result, err
+}
+
+func (conn *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := conn.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	var rows driver.Rows
+
+	err := conn.instrumentation.observe(ctx, conn.system, query, args, func(ctx context.Context) (err error) {
+		rows, err = queryer.QueryContext(ctx, query, args)
+		return err
+	})
+
+	return rows, err
+}
+
+func (conn *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := conn.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (conn *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := conn.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (conn *instrumentedConn) IsValid() bool {
+	if validator, ok := conn.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+func (conn *instrumentedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := conn.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
+
+func (stmt *instrumentedStmt) Close() error {
+	return stmt.stmt.Close()
+}
+
+func (stmt *instrumentedStmt) NumInput() int {
+	return stmt.stmt.NumInput()
+}
+
+func (stmt *instrumentedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return stmt.ExecContext(context.Background(), valuesToNamed(args))
+}
+
+func (stmt *instrumentedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return stmt.QueryContext(context.Background(), valuesToNamed(args))
+}
+
+func (stmt *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+
+	exec := func(ctx context.Context) (err error) {
+		if execer, ok := stmt.stmt.(driver.StmtExecContext); ok {
+			result, err = execer.ExecContext(ctx, args)
+			return err
+		}
+
+		result, err = stmt.stmt.Exec(namedToValues(args))
+		return err
+	}
+
+	if stmt.batch {
+		return result, exec(ctx)
+	}
+
+	err := stmt.conn.instrumentation.observe(ctx, stmt.conn.system, stmt.query, args, exec)
+
+	return result, err
+}
+
+func (stmt *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+
+	err := stmt.conn.instrumentation.observe(ctx, stmt.conn.system, stmt.query, args, func(ctx context.Context) (err error) {
+		if queryer, ok := stmt.stmt.(driver.StmtQueryContext); ok {
+			rows, err = queryer.QueryContext(ctx, args)
+			return err
+		}
+
+		rows, err = stmt.stmt.Query(namedToValues(args))
+		return err
+	})
+
+	return rows, err
+}
+
+// CheckNamedValue prefers the checker of the statement and falls back to
+// the one of the connection, as database/sql does for unwrapped drivers.
+func (stmt *instrumentedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := stmt.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return stmt.conn.CheckNamedValue(value)
+}
+
+// Commit observes the clickhouse batches of the transaction, which are
+// sent when it commits.
+func (tx *instrumentedTx) Commit() error {
+	batches := tx.conn.batches
+	tx.conn.batches = nil
+
+	if len(batches) == 0 {
+		return tx.tx.Commit()
+	}
+
+	return tx.conn.instrumentation.observe(tx.ctx, tx.conn.system, strings.Join(batches, "; "), nil, func(context.Context) error {
+		return tx.tx.Commit()
+	})
+}
+
+func (tx *instrumentedTx) Rollback() error {
+	tx.conn.batches = nil
+
+	return tx.tx.Rollback()
+}
+
+func namedToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	return values
+}
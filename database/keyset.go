@@ -0,0 +1,218 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cetnfurkan/core/http"
+
+	entsql "entgo.io/ent/dialect/sql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// Keyset is the sort order of a cursor paginated query.
+	//
+	// The columns must identify a row uniquely, e.g. created_at and id,
+	// and are compared as a row value, so they share one direction.
+	Keyset struct {
+		Columns    []string
+		Descending bool
+	}
+)
+
+// Ent returns an ent predicate that selects the page after or before
+// the cursor of the pagination, an ent order option that orders it by
+// the keyset and the limit of the query. The limit fetches one extra row
+// so KeysetPage can tell whether there is another page.
+//
+// The values are the sort keys scanned from the cursor and are
+// empty on the first page. The order and limit must be given to Order
+// and Limit, not Where, so counts leave them out, e.g.
+//
+//	where, order, limit := keyset.Ent(pagination, createdAt, id)
+//	query.Where(predicate.User(where)).Order(user.OrderOption(order)).Limit(limit)
+func (keyset Keyset) Ent(pagination *http.Pagination, values ...any) (func(*entsql.Selector), func(*entsql.Selector), int) {
+	backward := pagination.Before != nil
+	size := keysetSize(pagination)
+
+	where := func(s *entsql.Selector) {
+		if len(values) == 0 {
+			return
+		}
+
+		columns := make([]string, len(keyset.Columns))
+		for i, column := range keyset.Columns {
+			columns[i] = s.C(column)
+		}
+
+		if keyset.descending(backward) {
+			s.Where(entsql.CompositeLT(columns, values...))
+		} else {
+			s.Where(entsql.CompositeGT(columns, values...))
+		}
+	}
+
+	order := func(s *entsql.Selector) {
+		for _, column := range keyset.Columns {
+			if keyset.descending(backward) {
+				s.OrderBy(entsql.Desc(s.C(column)))
+			} else {
+				s.OrderBy(entsql.Asc(s.C(column)))
+			}
+		}
+	}
+
+	return where, order, size + 1
+}
+
+// Gorm returns a gorm scope that selects the page after or before
+// the cursor of the pagination, ordered by the keyset. It fetches one
+// extra row so KeysetPage can tell whether there is another page.
+//
+// The values are the sort keys scanned from the cursor and are
+// empty on the first page, e.g.
+//
+//	db.Scopes(keyset.Gorm(pagination, createdAt, id)).Find(&rows)
+func (keyset Keyset) Gorm(pagination *http.Pagination, values ...any) func(*gorm.DB) *gorm.DB {
+	backward := pagination.Before != nil
+	size := keysetSize(pagination)
+
+	return func(db *gorm.DB) *gorm.DB {
+		var (
+			columns = make([]any, len(keyset.Columns))
+			order   = clause.OrderBy{}
+		)
+
+		for i, column := range keyset.Columns {
+			columns[i] = clause.Column{Name: column}
+			order.Columns = append(order.Columns, clause.OrderByColumn{
+				Column: clause.Column{Name: column},
+				Desc:   keyset.descending(backward),
+			})
+		}
+
+		if len(values) > 0 {
+			db = db.Where(clause.Expr{
+				SQL:  keyset.comparison(backward),
+				Vars: append(columns, values...),
+			})
+		}
+
+		return db.Order(order).Limit(size + 1)
+	}
+}
+
+// Predicate returns the where clause and its args for raw queries,
+// e.g. "(created_at, id) > (?, ?)". The columns are not quoted.
+//
+// It returns an empty clause on the first page.
+func (keyset Keyset) Predicate(pagination *http.Pagination, values ...any) (string, []any) {
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	columns := make([]any, len(keyset.Columns))
+	for i, column := range keyset.Columns {
+		columns[i] = column
+	}
+
+	predicate := strings.Replace(keyset.comparison(pagination.Before != nil), "?", "%s", len(columns))
+
+	return fmt.Sprintf(predicate, columns...), values
+}
+
+// OrderBy returns the order clause for raw queries, e.g. "created_at ASC, id ASC".
+func (keyset Keyset) OrderBy(pagination *http.Pagination) string {
+	direction := "ASC"
+	if keyset.descending(pagination.Before != nil) {
+		direction = "DESC"
+	}
+
+	order := make([]string, len(keyset.Columns))
+	for i, column := range keyset.Columns {
+		order[i] = column + " " + direction
+	}
+
+	return strings.Join(order, ", ")
+}
+
+// KeysetPage trims the extra row fetched by the keyset query, restores
+// the order of a page read backwards and sets the next and prev cursors
+// of the pagination from the sort keys of the rows.
+//
+// It fills Data of the pagination and returns the page.
+func KeysetPage[E any](pagination *http.Pagination, rows []E, keys func(E) []any) ([]E, error) {
+	var (
+		backward = pagination.Before != nil
+		size     = keysetSize(pagination)
+		hasMore  = len(rows) > size
+	)
+
+	if hasMore {
+		rows = rows[:size]
+	}
+
+	if backward {
+		slices.Reverse(rows)
+	}
+
+	pagination.Data = rows
+
+	if len(rows) == 0 {
+		return rows, nil
+	}
+
+	// Moving forward there is a previous page unless this is the first one,
+	// moving backward there is always a next page.
+	hasNext := hasMore || backward
+	hasPrev := (hasMore && backward) || pagination.After != nil
+
+	if hasNext {
+		err := pagination.SetNextCursor(keys(rows[len(rows)-1])...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if hasPrev {
+		err := pagination.SetPrevCursor(keys(rows[0])...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rows, nil
+}
+
+// descending reports whether rows are read in descending order,
+// which is reversed when reading backwards.
+func (keyset Keyset) descending(backward bool) bool {
+	return keyset.Descending != backward
+}
+
+func (keyset Keyset) comparison(backward bool) string {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keyset.Columns)), ", ")
+
+	operator := ">"
+	if keyset.descending(backward) {
+		operator = "<"
+	}
+
+	return fmt.Sprintf("(%s) %s (%s)", placeholders, operator, placeholders)
+}
+
+// keysetSize returns the page size, the default size is written back
+// so it shows up in the response.
+func keysetSize(pagination *http.Pagination) int {
+	size := defaultPageSize
+	if pagination.Size != nil && *pagination.Size > 0 {
+		size = *pagination.Size
+	}
+
+	pagination.Size = &size
+
+	return size
+}
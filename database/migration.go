@@ -0,0 +1,678 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"entgo.io/ent/dialect"
+	"github.com/pkg/errors"
+)
+
+const (
+	DialectPostgres   = dialect.Postgres
+	DialectMySQL      = dialect.MySQL
+	DialectSQLite     = dialect.SQLite
+	DialectClickhouse = "clickhouse"
+
+	defaultMigrationTable = "schema_migrations"
+)
+
+var (
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+	statementSeparator   = regexp.MustCompile(`;\s*(\n|$)`)
+)
+
+type (
+	// Migration is a single versioned schema change read from
+	// a pair of <version>_<name>.up.sql and <version>_<name>.down.sql files.
+	Migration struct {
+		Version uint64
+		Name    string
+		Up      string
+		Down    string
+	}
+
+	// Locker guards a migration run so that only one replica
+	// migrates the schema at a time.
+	Locker interface {
+		Lock(ctx context.Context) error
+		Unlock(ctx context.Context) error
+	}
+
+	Migrator struct {
+		db         *sql.DB
+		dialect    migrationDialect
+		migrations []*Migration
+		table      string
+		target     *uint64
+		dryRun     bool
+		locker     Locker
+	}
+
+	migratorOption func(*Migrator)
+
+	migrationOptions struct {
+		fsys fs.FS
+		opts []migratorOption
+	}
+
+	migrationDialect interface {
+		createTable(table string) string
+		selectVersions(table string) string
+		insertVersion(table string, migration *Migration) (string, []any)
+		deleteVersion(table string, migration *Migration) (string, []any)
+		statements(script string) []string
+		transactional() bool
+		locker(db *sql.DB, table string) Locker
+	}
+
+	postgresMigrationDialect struct{}
+
+	mysqlMigrationDialect struct{}
+
+	sqliteMigrationDialect struct{}
+
+	clickhouseMigrationDialect struct{}
+
+	// sessionLocker holds a session level lock on a dedicated connection.
+	sessionLocker struct {
+		db     *sql.DB
+		lock   func(ctx context.Context, conn *sql.Conn) error
+		unlock func(ctx context.Context, conn *sql.Conn) error
+		conn   *sql.Conn
+	}
+
+	// NoopLocker does not lock, for schemas migrated by a single process.
+	NoopLocker struct{}
+)
+
+// WithMigrations runs the migrations found in fsys right after the
+// database connection is established.
+//
+// Migration files are read from the root of fsys, use fs.Sub
+// for embedded sub directories.
+func WithMigrations[T any](fsys fs.FS, opts ...migratorOption) option[T] {
+	return func(o *options[T]) error {
+		o.migrations = &migrationOptions{
+			fsys: fsys,
+			opts: opts,
+		}
+		return nil
+	}
+}
+
+// WithMigrationTable sets the table that records applied versions.
+// Default is schema_migrations.
+func WithMigrationTable(table string) migratorOption {
+	return func(migrator *Migrator) {
+		migrator.table = table
+	}
+}
+
+// WithMigrationTarget migrates up or down to the given version
+// instead of the latest one.
+func WithMigrationTarget(version uint64) migratorOption {
+	return func(migrator *Migrator) {
+		migrator.target = &version
+	}
+}
+
+// WithMigrationDryRun logs the statements that would run without
+// executing them. The version table is still created.
+func WithMigrationDryRun() migratorOption {
+	return func(migrator *Migrator) {
+		migrator.dryRun = true
+	}
+}
+
+// WithMigrationLocker overrides the lock held during a migration run.
+// Postgres and MySQL use an advisory lock by default and SQLite does not
+// lock. ClickHouse has no advisory locks, so a locker must be given,
+// e.g. one backed by a distributed lock or NoopLocker.
+func WithMigrationLocker(locker Locker) migratorOption {
+	return func(migrator *Migrator) {
+		migrator.locker = locker
+	}
+}
+
+// NewMigrator creates a new migrator instance.
+//
+// It takes a database pool, a dialect name (DialectPostgres, DialectMySQL,
+// DialectSQLite or DialectClickhouse) and a file system that contains
+// the migration files.
+//
+// It returns an error
+// if the dialect is not supported,
+// if the dialect is clickhouse and WithMigrationLocker is not given
+// unless it is a dry run or
+// if the migration files can not be read.
+func NewMigrator(db *sql.DB, dialectName string, fsys fs.FS, opts ...migratorOption) (*Migrator, error) {
+	migrator := &Migrator{
+		db:    db,
+		table: defaultMigrationTable,
+	}
+
+	switch dialectName {
+	case DialectPostgres:
+		migrator.dialect = postgresMigrationDialect{}
+
+	case DialectMySQL:
+		migrator.dialect = mysqlMigrationDialect{}
+
+	case DialectSQLite:
+		migrator.dialect = sqliteMigrationDialect{}
+
+	case DialectClickhouse:
+		migrator.dialect = clickhouseMigrationDialect{}
+
+	default:
+		return nil, errors.Errorf("unsupported migration dialect: %s", dialectName)
+	}
+
+	for _, opt := range opts {
+		opt(migrator)
+	}
+
+	if migrator.locker == nil {
+		migrator.locker = migrator.dialect.locker(db, migrator.table)
+	}
+
+	if migrator.locker == nil && !migrator.dryRun {
+		return nil, errors.Wrapf(coreErrors.ErrMigrationLockerRequired, "%s has no advisory locks", dialectName)
+	}
+
+	migrations, err := readMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator.migrations = migrations
+
+	return migrator, nil
+}
+
+// Migrations returns the migrations read from the file system
+// sorted by version.
+func (migrator *Migrator) Migrations() []*Migration {
+	return migrator.migrations
+}
+
+// Version returns the highest applied version, or 0 if nothing is applied.
+func (migrator *Migrator) Version(ctx context.Context) (uint64, error) {
+	applied, err := migrator.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var version uint64
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+
+	return version, nil
+}
+
+// Migrate applies or reverts migrations until the target version is reached.
+// The target version is the latest migration unless WithMigrationTarget is given.
+func (migrator *Migrator) Migrate(ctx context.Context) error {
+	if !migrator.dryRun {
+		err := migrator.locker.Lock(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to acquire migration lock")
+		}
+
+		defer func() {
+			err := migrator.locker.Unlock(context.Background())
+			if err != nil {
+				log.Printf("failed to release migration lock: %v", err)
+			}
+		}()
+	}
+
+	_, err := migrator.db.ExecContext(ctx, migrator.dialect.createTable(migrator.table))
+	if err != nil {
+		return errors.Wrap(err, "failed to create migration table")
+	}
+
+	applied, err := migrator.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	target := migrator.latest()
+	if migrator.target != nil {
+		target = *migrator.target
+	}
+
+	for _, migration := range migrator.migrations {
+		if migration.Version > target || applied[migration.Version] {
+			continue
+		}
+
+		err = migrator.apply(ctx, migration, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := len(migrator.migrations) - 1; i >= 0; i-- {
+		migration := migrator.migrations[i]
+		if migration.Version <= target || !applied[migration.Version] {
+			continue
+		}
+
+		err = migrator.apply(ctx, migration, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (migrator *Migrator) apply(ctx context.Context, migration *Migration, up bool) error {
+	var (
+		direction    = "up"
+		script       = migration.Up
+		query, args  = migrator.dialect.insertVersion(migrator.table, migration)
+		migrationTag = fmt.Sprintf("%d_%s", migration.Version, migration.Name)
+	)
+
+	if !up {
+		direction = "down"
+		script = migration.Down
+		query, args = migrator.dialect.deleteVersion(migrator.table, migration)
+
+		if strings.TrimSpace(script) == "" {
+			return errors.Errorf("migration %s has no down script", migrationTag)
+		}
+	}
+
+	statements := migrator.dialect.statements(script)
+
+	if migrator.dryRun {
+		log.Printf("[DRY RUN] migration %s %s", migrationTag, direction)
+		for _, statement := range statements {
+			log.Printf("[DRY RUN] %s", statement)
+		}
+		return nil
+	}
+
+	log.Printf("migration %s %s", migrationTag, direction)
+
+	err := migrator.exec(ctx, statements, query, args)
+	if err != nil {
+		return errors.Wrapf(err, "migration %s %s failed", migrationTag, direction)
+	}
+
+	return nil
+}
+
+func (migrator *Migrator) exec(ctx context.Context, statements []string, query string, args []any) error {
+	if !migrator.dialect.transactional() {
+		for _, statement := range statements {
+			_, err := migrator.db.ExecContext(ctx, statement)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := migrator.db.ExecContext(ctx, query, args...)
+		return err
+	}
+
+	tx, err := migrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, statement := range statements {
+		_, err = tx.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (migrator *Migrator) applied(ctx context.Context) (map[uint64]bool, error) {
+	rows, err := migrator.db.QueryContext(ctx, migrator.dialect.selectVersions(migrator.table))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read applied migrations")
+	}
+
+	defer rows.Close()
+
+	applied := make(map[uint64]bool)
+	for rows.Next() {
+		var version uint64
+		err = rows.Scan(&version)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan applied migration")
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+func (migrator *Migrator) latest() uint64 {
+	if len(migrator.migrations) == 0 {
+		return 0
+	}
+
+	return migrator.migrations[len(migrator.migrations)-1].Version
+}
+
+func (opts *migrationOptions) run(dialectName string, db *sql.DB) error {
+	migrator, err := NewMigrator(db, dialectName, opts.fsys, opts.opts...)
+	if err != nil {
+		return err
+	}
+
+	return migrator.Migrate(context.Background())
+}
+
+func readMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read migration files")
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid migration version in %s", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read migration file %s", entry.Name())
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, errors.Errorf("duplicate migration version %d: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" {
+			return nil, errors.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// splitStatements splits the script on semicolons at the end of a line.
+func splitStatements(script string) []string {
+	var statements []string
+
+	for _, statement := range statementSeparator.Split(script, -1) {
+		statement = strings.TrimSpace(statement)
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements
+}
+
+// lockKey hashes a lock name into a key usable by postgres advisory locks.
+func lockKey(name string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	return int64(hash.Sum64())
+}
+
+func (postgresMigrationDialect) createTable(table string) string {
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version BIGINT PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())",
+		table,
+	)
+}
+
+func (postgresMigrationDialect) selectVersions(table string) string {
+	return fmt.Sprintf("SELECT version FROM %s", table)
+}
+
+func (postgresMigrationDialect) insertVersion(table string, migration *Migration) (string, []any) {
+	return fmt.Sprintf("INSERT INTO %s (version, name) VALUES ($1, $2)", table), []any{migration.Version, migration.Name}
+}
+
+func (postgresMigrationDialect) deleteVersion(table string, migration *Migration) (string, []any) {
+	return fmt.Sprintf("DELETE FROM %s WHERE version = $1", table), []any{migration.Version}
+}
+
+func (postgresMigrationDialect) statements(script string) []string {
+	return []string{script}
+}
+
+func (postgresMigrationDialect) transactional() bool {
+	return true
+}
+
+func (postgresMigrationDialect) locker(db *sql.DB, table string) Locker {
+	return newAdvisoryLock(db, table)
+}
+
+func (mysqlMigrationDialect) createTable(table string) string {
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version BIGINT UNSIGNED PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+		table,
+	)
+}
+
+func (mysqlMigrationDialect) selectVersions(table string) string {
+	return fmt.Sprintf("SELECT version FROM %s", table)
+}
+
+func (mysqlMigrationDialect) insertVersion(table string, migration *Migration) (string, []any) {
+	return fmt.Sprintf("INSERT INTO %s (version, name) VALUES (?, ?)", table), []any{migration.Version, migration.Name}
+}
+
+func (mysqlMigrationDialect) deleteVersion(table string, migration *Migration) (string, []any) {
+	return fmt.Sprintf("DELETE FROM %s WHERE version = ?", table), []any{migration.Version}
+}
+
+// statements splits the script since the mysql driver does not allow
+// multiple statements in one query by default.
+func (mysqlMigrationDialect) statements(script string) []string {
+	return splitStatements(script)
+}
+
+// transactional is false since mysql commits implicitly on DDL statements.
+func (mysqlMigrationDialect) transactional() bool {
+	return false
+}
+
+func (mysqlMigrationDialect) locker(db *sql.DB, table string) Locker {
+	return &sessionLocker{
+		db: db,
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			var acquired sql.NullInt64
+
+			err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", table).Scan(&acquired)
+			if err != nil {
+				return err
+			}
+
+			if acquired.Int64 != 1 {
+				return errors.Errorf("failed to get lock %s", table)
+			}
+
+			return nil
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn) error {
+			_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", table)
+			return err
+		},
+	}
+}
+
+func (sqliteMigrationDialect) createTable(table string) string {
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+		table,
+	)
+}
+
+func (sqliteMigrationDialect) selectVersions(table string) string {
+	return fmt.Sprintf("SELECT version FROM %s", table)
+}
+
+func (sqliteMigrationDialect) insertVersion(table string, migration *Migration) (string, []any) {
+	return fmt.Sprintf("INSERT INTO %s (version, name) VALUES (?, ?)", table), []any{migration.Version, migration.Name}
+}
+
+func (sqliteMigrationDialect) deleteVersion(table string, migration *Migration) (string, []any) {
+	return fmt.Sprintf("DELETE FROM %s WHERE version = ?", table), []any{migration.Version}
+}
+
+func (sqliteMigrationDialect) statements(script string) []string {
+	return []string{script}
+}
+
+func (sqliteMigrationDialect) transactional() bool {
+	return true
+}
+
+func (sqliteMigrationDialect) locker(db *sql.DB, table string) Locker {
+	return NoopLocker{}
+}
+
+// ClickHouse has neither transactions nor deletes that take effect
+// immediately, so the version table is append only and the latest row
+// of a version decides whether it is applied.
+func (clickhouseMigrationDialect) createTable(table string) string {
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version UInt64, name String, applied UInt8, applied_at DateTime64(9) DEFAULT now64(9)) ENGINE = MergeTree ORDER BY (version, applied_at)",
+		table,
+	)
+}
+
+func (clickhouseMigrationDialect) selectVersions(table string) string {
+	return fmt.Sprintf("SELECT version FROM %s GROUP BY version HAVING argMax(applied, applied_at) = 1", table)
+}
+
+func (clickhouseMigrationDialect) insertVersion(table string, migration *Migration) (string, []any) {
+	return fmt.Sprintf("INSERT INTO %s (version, name, applied) VALUES (?, ?, 1)", table), []any{migration.Version, migration.Name}
+}
+
+func (clickhouseMigrationDialect) deleteVersion(table string, migration *Migration) (string, []any) {
+	return fmt.Sprintf("INSERT INTO %s (version, name, applied) VALUES (?, ?, 0)", table), []any{migration.Version, migration.Name}
+}
+
+// statements splits the script since the clickhouse driver executes
+// a single statement at a time.
+func (clickhouseMigrationDialect) statements(script string) []string {
+	return splitStatements(script)
+}
+
+func (clickhouseMigrationDialect) transactional() bool {
+	return false
+}
+
+// locker returns no locker, concurrent runs would apply the same
+// migrations twice, so NewMigrator requires one to be given.
+func (clickhouseMigrationDialect) locker(db *sql.DB, table string) Locker {
+	return nil
+}
+
+// Lock blocks until the session level lock is acquired
+// on a dedicated connection.
+func (locker *sessionLocker) Lock(ctx context.Context) error {
+	conn, err := locker.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = locker.lock(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	locker.conn = conn
+
+	return nil
+}
+
+// Unlock releases the lock. If it fails, the connection is closed
+// instead of being returned to the pool, which releases the lock as well.
+func (locker *sessionLocker) Unlock(ctx context.Context) error {
+	if locker.conn == nil {
+		return nil
+	}
+
+	conn := locker.conn
+	locker.conn = nil
+
+	err := locker.unlock(ctx, conn)
+	if err != nil {
+		discardConn(conn)
+		return err
+	}
+
+	conn.Close()
+
+	return nil
+}
+
+func (NoopLocker) Lock(ctx context.Context) error {
+	return nil
+}
+
+func (NoopLocker) Unlock(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/cetnfurkan/core/config"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+type (
+	mysqlDatabase[T any] struct {
+		cfg    *mysqlDatabaseConfig
+		client *T
+		pool   *sql.DB
+
+		instrumentation *instrumentation
+	}
+
+	mysqlDatabaseConfig struct {
+		*config.Database
+		Extra mysqlDatabaseConfigExtra
+	}
+
+	mysqlDatabaseConfigExtra struct {
+		sqlPoolConfigExtra `mapstructure:",squash"`
+
+		Charset   string `mapstructure:"charset"`
+		Collation string `mapstructure:"collation"`
+		TLS       string `mapstructure:"tls"`
+		Loc       string `mapstructure:"loc"`
+	}
+)
+
+// NewMySQLDatabase creates a new mysql database instance.
+//
+// It takes a config instance and returns a new database interface instance.
+//
+// It will panic
+// if it fails to unmarhal extra config data,
+// if it fails to connect to mysql database or
+// if it fails to run migrations given by WithMigrations.
+func NewMySQLDatabase[T any](cfg *config.Database, createClient func(*entsql.Driver) *T, opts ...option[T]) Database {
+	database := &mysqlDatabase[T]{
+		cfg: &mysqlDatabaseConfig{
+			Database: cfg,
+		},
+	}
+
+	database.UnmarshalExtra()
+
+	dbOptions := newOptions(opts)
+	database.instrumentation = dbOptions.instrumentation
+
+	err := database.createPool()
+	if err != nil {
+		log.Fatal("failed to create mysql pool: ", err)
+	}
+
+	if dbOptions.migrations != nil {
+		err = dbOptions.migrations.run(DialectMySQL, database.pool)
+		if err != nil {
+			log.Fatal("failed to run mysql migrations: ", err)
+		}
+	}
+
+	driver := entsql.OpenDB(dialect.MySQL, database.pool)
+	database.client = createClient(driver)
+
+	dbOptions.applyCallbacks(database.client)
+
+	return database
+}
+
+func (database *mysqlDatabase[T]) Get() any {
+	return database.client
+}
+
+// DB returns the connection pool.
+func (database *mysqlDatabase[T]) DB() *sql.DB {
+	return database.pool
+}
+
+func (database *mysqlDatabase[T]) UnmarshalExtra() {
+	err := mapstructure.Decode(database.cfg.Database.Extra, &database.cfg.Extra)
+	if err != nil {
+		log.Fatal("failed to decode extra config into struct: ", err)
+	}
+}
+
+func (database *mysqlDatabase[T]) createPool() error {
+	ctx, cancel := context.WithTimeout(context.Background(), database.cfg.Extra.connTimeOut())
+	defer cancel()
+
+	connector, err := mysql.NewConnector(database.getConnectionConfig())
+	if err != nil {
+		return errors.Wrap(err, "failed to create mysql connector")
+	}
+
+	database.pool = sql.OpenDB(database.instrumentation.wrap(connector, systemMySQL))
+
+	database.cfg.Extra.configure(database.pool)
+
+	err = database.cfg.Extra.ping(ctx, database.pool)
+	if err != nil {
+		return errors.Wrap(err, "mysql connection pool ping failed")
+	}
+
+	return nil
+}
+
+func (database *mysqlDatabase[T]) getConnectionConfig() *mysql.Config {
+	connectionConfig := mysql.NewConfig()
+
+	connectionConfig.User = database.cfg.User
+	connectionConfig.Passwd = database.cfg.Password
+	connectionConfig.Net = "tcp"
+	connectionConfig.Addr = net.JoinHostPort(database.cfg.Host, strconv.Itoa(database.cfg.Port))
+	connectionConfig.DBName = database.cfg.Name
+	connectionConfig.Timeout = database.cfg.Extra.connTimeOut()
+	connectionConfig.Collation = database.cfg.Extra.Collation
+	connectionConfig.TLSConfig = database.cfg.Extra.TLS
+
+	// ent scans time columns into time.Time.
+	connectionConfig.ParseTime = true
+
+	if database.cfg.Extra.Charset != "" {
+		connectionConfig.Params = map[string]string{
+			"charset": database.cfg.Extra.Charset,
+		}
+	}
+
+	if database.cfg.Extra.Loc != "" {
+		loc, err := time.LoadLocation(database.cfg.Extra.Loc)
+		if err != nil {
+			log.Fatal("failed to load mysql location: ", err)
+		}
+
+		connectionConfig.Loc = loc
+	}
+
+	return connectionConfig
+}
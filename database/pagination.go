@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/cetnfurkan/core/http"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+)
+
+type (
+	// EntPaginatedQuery is implemented by the query builders ent generates,
+	// e.g. *ent.UserQuery.
+	EntPaginatedQuery[Q any, E any] interface {
+		Limit(limit int) Q
+		Offset(offset int) Q
+		Clone() Q
+		Count(ctx context.Context) (int, error)
+		All(ctx context.Context) ([]E, error)
+	}
+
+	// CountEstimator returns an estimated number of rows of a query.
+	CountEstimator func(ctx context.Context) (int, error)
+
+	paginationOptions struct {
+		defaultSize        int
+		estimator          CountEstimator
+		estimatedThreshold int
+	}
+
+	paginationOption func(*paginationOptions)
+)
+
+// WithDefaultPageSize sets the size used when the pagination has none.
+// Default is 20.
+func WithDefaultPageSize(size int) paginationOption {
+	return func(opts *paginationOptions) {
+		opts.defaultSize = size
+	}
+}
+
+// WithEstimatedCount uses the estimator for the total when it estimates
+// at least threshold rows, and counts exactly below it.
+//
+// The estimator is meant for the whole table, e.g. EstimatePostgresRowCount,
+// so it is only used for queries without conditions. Queries with where
+// conditions, joins or traversals are always counted exactly.
+func WithEstimatedCount(estimator CountEstimator, threshold int) paginationOption {
+	return func(opts *paginationOptions) {
+		opts.estimator = estimator
+		opts.estimatedThreshold = threshold
+	}
+}
+
+// PaginateEnt runs the ent query for the page of the pagination.
+//
+// It counts the rows matching the query, fills TotalElement and Data
+// of the pagination and returns the page.
+func PaginateEnt[Q EntPaginatedQuery[Q, E], E any](ctx context.Context, pagination *http.Pagination, query Q, opts ...paginationOption) ([]E, error) {
+	paginationOpts := newPaginationOptions(opts)
+	limit, offset := paginationOpts.bounds(pagination)
+
+	total, err := paginationOpts.count(ctx, entQueryFiltered(query), query.Clone().Count)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count ent query")
+	}
+
+	data, err := query.Limit(limit).Offset(offset).All(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run ent query")
+	}
+
+	pagination.TotalElement = &total
+	pagination.Data = data
+
+	return data, nil
+}
+
+// PaginateGorm runs the gorm query for the page of the pagination.
+//
+// It counts the rows matching the query, fills TotalElement and Data
+// of the pagination and returns the page. The model of the query
+// is set to E if the query has none.
+func PaginateGorm[E any](ctx context.Context, pagination *http.Pagination, query *gorm.DB, opts ...paginationOption) ([]E, error) {
+	paginationOpts := newPaginationOptions(opts)
+	limit, offset := paginationOpts.bounds(pagination)
+
+	// WithContext starts a new session, so count and find don't share conditions.
+	query = query.WithContext(ctx)
+	if query.Statement.Model == nil {
+		query = query.Model(new(E))
+	}
+
+	_, filtered := query.Statement.Clauses["WHERE"]
+	filtered = filtered || len(query.Statement.Joins) > 0
+
+	total, err := paginationOpts.count(ctx, filtered, func(context.Context) (int, error) {
+		var total int64
+
+		err := query.Count(&total).Error
+		return int(total), err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count gorm query")
+	}
+
+	var data []E
+
+	err = query.Limit(limit).Offset(offset).Find(&data).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run gorm query")
+	}
+
+	pagination.TotalElement = &total
+	pagination.Data = data
+
+	return data, nil
+}
+
+// EstimatePostgresRowCount returns a count estimator for the whole table
+// from the postgres statistics. It is refreshed by ANALYZE and autovacuum.
+// Rows hidden by interceptors, e.g. soft deleted ones, are included.
+func EstimatePostgresRowCount(db *sql.DB, table string) CountEstimator {
+	return func(ctx context.Context) (int, error) {
+		var estimate int
+
+		err := db.QueryRowContext(ctx, "SELECT reltuples::bigint FROM pg_class WHERE oid = $1::regclass", table).Scan(&estimate)
+		if err != nil {
+			return 0, err
+		}
+
+		return estimate, nil
+	}
+}
+
+func newPaginationOptions(opts []paginationOption) *paginationOptions {
+	paginationOpts := &paginationOptions{
+		defaultSize: defaultPageSize,
+	}
+
+	for _, opt := range opts {
+		opt(paginationOpts)
+	}
+
+	return paginationOpts
+}
+
+// bounds returns the limit and offset of the pagination. A missing page
+// is the first one and a missing size is the default size, both are
+// written back so they show up in the response.
+func (opts *paginationOptions) bounds(pagination *http.Pagination) (int, int) {
+	page := 1
+	if pagination.Page != nil && *pagination.Page > 0 {
+		page = *pagination.Page
+	}
+
+	size := opts.defaultSize
+	if pagination.Size != nil && *pagination.Size > 0 {
+		size = *pagination.Size
+	}
+
+	pagination.Page = &page
+	pagination.Size = &size
+
+	return size, pagination.Offset()
+}
+
+// count returns the estimate for unfiltered queries if it is above the
+// threshold, and the exact count otherwise.
+func (opts *paginationOptions) count(ctx context.Context, filtered bool, count func(context.Context) (int, error)) (int, error) {
+	if opts.estimator != nil && !filtered {
+		estimate, err := opts.estimator(ctx)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to estimate count")
+		}
+
+		if estimate >= opts.estimatedThreshold {
+			return estimate, nil
+		}
+	}
+
+	return count(ctx)
+}
+
+// entQueryFiltered reports whether the ent query has where predicates or
+// is a traversal. The generated query types are not known here, so it uses
+// reflection and reports true for queries it can't inspect.
+func entQueryFiltered(query any) bool {
+	value := reflect.Indirect(reflect.ValueOf(query))
+	if value.Kind() != reflect.Struct {
+		return true
+	}
+
+	predicates := value.FieldByName("predicates")
+	path := value.FieldByName("path")
+	selector := value.FieldByName("sql")
+
+	if !predicates.IsValid() || !path.IsValid() || !selector.IsValid() {
+		return true
+	}
+
+	return predicates.Len() > 0 || !path.IsNil() || !selector.IsNil()
+}
@@ -11,21 +11,31 @@ import (
 	"time"
 
 	"github.com/cetnfurkan/core/config"
+	coreErrors "github.com/cetnfurkan/core/errors"
 
 	"entgo.io/ent/dialect"
 	entsql "entgo.io/ent/dialect/sql"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 )
 
+var (
+	_ ConnPool = (*pgxpool.Pool)(nil)
+)
+
 type (
 	postgresDatabase[T any] struct {
-		cfg    *postgresDatabaseConfig
-		client *T
-		pool   *sql.DB
+		cfg      *postgresDatabaseConfig
+		client   *T
+		pool     *sql.DB
+		native   *pgxpool.Pool
+		replicas *postgresReplicaRouter
+
+		instrumentation *instrumentation
 	}
 
 	postgresDatabaseConfig struct {
@@ -34,31 +44,68 @@ type (
 	}
 
 	postgresDatabaseConfigExtra struct {
+		sqlPoolConfigExtra `mapstructure:",squash"`
+
 		SSLMode                  string `mapstructure:"sslmode"`
-		ConnRetry                int    `mapstructure:"connRetry"`
-		MaxOpenConns             int    `mapstructure:"maxOpenConns"`
-		MaxIdleConns             int    `mapstructure:"maxIdleConns"`
 		DescriptionCacheCapacity int    `mapstructure:"descriptionCacheCapacity"`
 		StatementCacheCapacity   int    `mapstructure:"statementCacheCapacity"`
-		ConnTimeOut              int    `mapstructure:"connTimeOut"`
-		MaxOpenConnTTL           int    `mapstructure:"maxOpenConnTTL"`
-		MaxIdleConnTTL           int    `mapstructure:"maxIdleConnTTL"`
 		QueryExecMode            string `mapstructure:"queryExecMode"`
+		PoolMode                 string `mapstructure:"poolMode"`
+		SearchPath               string `mapstructure:"searchPath"`
+
+		Replicas                   []postgresReplicaConfig `mapstructure:"replicas"`
+		ReplicaPolicy              string                  `mapstructure:"replicaPolicy"`
+		ReplicaHealthCheckInterval int                     `mapstructure:"replicaHealthCheckInterval"`
+
+		TenantMode        string `mapstructure:"tenantMode"`
+		MaxTenants        int    `mapstructure:"maxTenants"`
+		TenantIdleTimeout int    `mapstructure:"tenantIdleTimeout"`
+	}
+
+	postgresReplicaConfig struct {
+		Host string `mapstructure:"host"`
+		Port int    `mapstructure:"port"`
 	}
 
+	// ConnPool is the native pgx pool, available when poolMode is native.
+	// It shares its connections with the ent driver.
 	ConnPool interface {
 		Acquire(ctx context.Context) (*pgxpool.Conn, error)
+		Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+		Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+		QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+		SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+		CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+		Begin(ctx context.Context) (pgx.Tx, error)
 	}
+
+	connPooler interface {
+		ConnPool() (ConnPool, error)
+	}
+)
+
+const (
+	PoolModeStdlib = "stdlib"
+	PoolModeNative = "native"
 )
 
 // NewPostgresDatabase creates a new postgres database instance.
 //
 // It takes a config instance and returns a new database interface instance.
 //
+// When replicas are configured in extra config data, read only queries
+// are routed to healthy replicas and everything else to the primary.
+// Use Primary to force a query to the primary. Replicas are health checked
+// in the background until the database is closed with Close.
+//
+// When poolMode is native, a pgx pool is created and shared with the
+// ent driver. Use GetConnPool to access it.
+//
 // It will panic
 // if it fails to unmarhal extra config data,
-// if it fails to create a new PGX pool or
-// if it fails to connect to postgres database.
+// if it fails to create a new PGX pool,
+// if it fails to connect to postgres database or any of its replicas or
+// if it fails to run migrations given by WithMigrations.
 func NewPostgresDatabase[T any](cfg *config.Database, createClient func(*entsql.Driver) *T, opts ...option[T]) Database {
 	database := &postgresDatabase[T]{
 		cfg: &postgresDatabaseConfig{
@@ -68,20 +115,14 @@ func NewPostgresDatabase[T any](cfg *config.Database, createClient func(*entsql.
 
 	database.UnmarshalExtra()
 
-	err := database.createPool()
+	dbOptions := newOptions(opts)
+
+	err := database.open(createClient, dbOptions)
 	if err != nil {
-		log.Fatal("failed to create new PGX: ", err)
+		log.Fatal("failed to open postgres database: ", err)
 	}
 
-	driver := entsql.OpenDB(dialect.Postgres, database.pool)
-	database.client = createClient(driver)
-
-	for _, opt := range opts {
-		err := opt(database.client)
-		if err != nil {
-			log.Fatalf("failed to apply option: %v", err)
-		}
-	}
+	dbOptions.applyCallbacks(database.client)
 
 	return database
 }
@@ -90,34 +131,200 @@ func (database *postgresDatabase[T]) Get() any {
 	return database.client
 }
 
+// ConnPool returns the native pgx pool.
+// It returns an error if poolMode is not native.
+func (database *postgresDatabase[T]) ConnPool() (ConnPool, error) {
+	if database.native == nil {
+		return nil, coreErrors.ErrConnPoolNotFound
+	}
+
+	return database.native, nil
+}
+
+// GetConnPool returns the native pgx pool of the given database.
+// It returns an error if the database has no native pool.
+func GetConnPool(database Database) (ConnPool, error) {
+	pooler, ok := database.(connPooler)
+	if !ok {
+		return nil, coreErrors.ErrConnPoolNotFound
+	}
+
+	return pooler.ConnPool()
+}
+
 func (database *postgresDatabase[T]) UnmarshalExtra() {
-	err := mapstructure.Decode(database.cfg.Database.Extra, &database.cfg.Extra)
+	err := database.cfg.unmarshalExtra()
 	if err != nil {
 		log.Fatal("failed to decode extra config into struct: ", err)
 	}
 }
 
+// open creates the pools, runs the migrations and creates the client.
+func (database *postgresDatabase[T]) open(createClient func(*entsql.Driver) *T, dbOptions *options[T]) error {
+	database.instrumentation = dbOptions.instrumentation
+
+	err := database.createPool()
+	if err != nil {
+		return errors.Wrap(err, "failed to create new PGX")
+	}
+
+	if dbOptions.migrations != nil {
+		err = dbOptions.migrations.run(DialectPostgres, database.pool)
+		if err != nil {
+			database.close()
+			return errors.Wrap(err, "failed to run postgres migrations")
+		}
+	}
+
+	if len(database.cfg.Extra.Replicas) > 0 {
+		err = database.createReplicas()
+		if err != nil {
+			database.close()
+			return errors.Wrap(err, "failed to create postgres replicas")
+		}
+	}
+
+	driver := entsql.OpenDB(dialect.Postgres, database.driverDB())
+	database.client = createClient(driver)
+
+	return nil
+}
+
+// Close closes the pools of the database and stops the replica health check.
+func (database *postgresDatabase[T]) Close() error {
+	database.close()
+	return nil
+}
+
+// close closes the pools of the database.
+func (database *postgresDatabase[T]) close() {
+	if database.replicas != nil {
+		database.replicas.Close()
+	}
+
+	database.pool.Close()
+
+	if database.native != nil {
+		database.native.Close()
+	}
+}
+
+// DB returns the primary connection pool.
+func (database *postgresDatabase[T]) DB() *sql.DB {
+	return database.pool
+}
+
+// driverDB returns the pool given to the ent driver. Reads are routed to
+// replicas through it when replicas are configured.
+func (database *postgresDatabase[T]) driverDB() *sql.DB {
+	if database.replicas != nil {
+		return database.replicas.DB()
+	}
+
+	return database.pool
+}
+
 func (database *postgresDatabase[T]) createPool() error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(database.cfg.Extra.ConnTimeOut)*time.Second)
+	var (
+		err error
+	)
+
+	if database.cfg.Extra.PoolMode == PoolModeNative {
+		return database.createNativePool()
+	}
+
+	database.pool, err = database.openPool(database.cfg.Host, database.cfg.Port)
+	if err != nil {
+		return errors.Wrap(err, "postgres connection pool ping failed")
+	}
+
+	return nil
+}
+
+// createNativePool creates a pgx pool and a *sql.DB on top of it,
+// so the ent driver and ConnPool share the same connections.
+func (database *postgresDatabase[T]) createNativePool() error {
+	ctx, cancel := context.WithTimeout(context.Background(), database.cfg.Extra.connTimeOut())
 	defer cancel()
 
-	database.pool = stdlib.OpenDB(database.getConnectionConfig())
+	poolConfig, err := pgxpool.ParseConfig(database.getDSN(database.cfg.Host, database.cfg.Port))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse pgx pool config")
+	}
 
-	database.pool.SetConnMaxIdleTime(time.Duration(database.cfg.Extra.MaxIdleConnTTL) * time.Second)
-	database.pool.SetConnMaxLifetime(time.Duration(database.cfg.Extra.MaxOpenConnTTL) * time.Second)
-	database.pool.SetMaxOpenConns(database.cfg.Extra.MaxOpenConns)
-	database.pool.SetMaxIdleConns(database.cfg.Extra.MaxIdleConns)
+	if database.cfg.Extra.MaxOpenConns > 0 {
+		poolConfig.MaxConns = int32(database.cfg.Extra.MaxOpenConns)
+	}
 
-	err := database.pingConnection(ctx, database.pool)
+	if database.cfg.Extra.MaxOpenConnTTL > 0 {
+		poolConfig.MaxConnLifetime = time.Duration(database.cfg.Extra.MaxOpenConnTTL) * time.Second
+	}
+
+	if database.cfg.Extra.MaxIdleConnTTL > 0 {
+		poolConfig.MaxConnIdleTime = time.Duration(database.cfg.Extra.MaxIdleConnTTL) * time.Second
+	}
+
+	database.native, err = pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create pgx pool")
+	}
+
+	// Same as stdlib.OpenDBFromPool, idle connections are kept by the pgx pool.
+	database.pool = sql.OpenDB(database.instrumentation.wrap(stdlib.GetPoolConnector(database.native), systemPostgres))
+	database.pool.SetMaxIdleConns(0)
+
+	err = database.cfg.Extra.ping(ctx, database.pool)
 	if err != nil {
+		database.pool.Close()
+		database.native.Close()
 		return errors.Wrap(err, "postgres connection pool ping failed")
 	}
 
 	return nil
 }
 
-func (database *postgresDatabase[T]) getConnectionConfig() pgx.ConnConfig {
-	connectionConfig, err := pgx.ParseConfig(database.getDSN())
+func (database *postgresDatabase[T]) createReplicas() error {
+	replicas := make([]*postgresReplica, 0, len(database.cfg.Extra.Replicas))
+
+	for _, replica := range database.cfg.Extra.Replicas {
+		pool, err := database.openPool(replica.Host, replica.Port)
+		if err != nil {
+			closeReplicas(replicas)
+			return errors.Wrapf(err, "postgres replica %s:%d ping failed", replica.Host, replica.Port)
+		}
+
+		replicas = append(replicas, newPostgresReplica(net.JoinHostPort(replica.Host, strconv.Itoa(replica.Port)), pool))
+	}
+
+	database.replicas = newPostgresReplicaRouter(
+		database.pool,
+		replicas,
+		database.cfg.Extra.ReplicaPolicy,
+		time.Duration(database.cfg.Extra.ReplicaHealthCheckInterval)*time.Second,
+	)
+
+	return nil
+}
+
+func (database *postgresDatabase[T]) openPool(host string, port int) (*sql.DB, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), database.cfg.Extra.connTimeOut())
+	defer cancel()
+
+	pool := sql.OpenDB(database.instrumentation.wrap(stdlib.GetConnector(database.getConnectionConfig(host, port)), systemPostgres))
+
+	database.cfg.Extra.configure(pool)
+
+	err := database.cfg.Extra.ping(ctx, pool)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+func (database *postgresDatabase[T]) getConnectionConfig(host string, port int) pgx.ConnConfig {
+	connectionConfig, err := pgx.ParseConfig(database.getDSN(host, port))
 	if err != nil {
 		log.Fatal("failed to parse connection config: ", err)
 	}
@@ -125,51 +332,43 @@ func (database *postgresDatabase[T]) getConnectionConfig() pgx.ConnConfig {
 	return *connectionConfig
 }
 
-func (database *postgresDatabase[T]) getDSN() string {
+func (database *postgresDatabase[T]) getDSN(host string, port int) string {
+	return database.cfg.getDSN(host, port)
+}
+
+func (cfg *postgresDatabaseConfig) unmarshalExtra() error {
+	return mapstructure.Decode(cfg.Database.Extra, &cfg.Extra)
+}
+
+func (cfg *postgresDatabaseConfig) getDSN(host string, port int) string {
 	var (
 		query = make(url.Values)
 	)
 
-	if database.cfg.Extra.StatementCacheCapacity >= 0 {
-		query.Set("statement_cache_capacity", strconv.Itoa(database.cfg.Extra.StatementCacheCapacity))
+	if cfg.Extra.StatementCacheCapacity >= 0 {
+		query.Set("statement_cache_capacity", strconv.Itoa(cfg.Extra.StatementCacheCapacity))
+	}
+
+	if cfg.Extra.DescriptionCacheCapacity >= 0 {
+		query.Set("description_cache_capacity", strconv.Itoa(cfg.Extra.DescriptionCacheCapacity))
 	}
 
-	if database.cfg.Extra.DescriptionCacheCapacity >= 0 {
-		query.Set("description_cache_capacity", strconv.Itoa(database.cfg.Extra.DescriptionCacheCapacity))
+	if cfg.Extra.QueryExecMode != "" {
+		query.Set("default_query_exec_mode", cfg.Extra.QueryExecMode)
 	}
 
-	if database.cfg.Extra.QueryExecMode != "" {
-		query.Set("default_query_exec_mode", database.cfg.Extra.QueryExecMode)
+	// Unknown parameters are sent to postgres as run-time parameters.
+	if cfg.Extra.SearchPath != "" {
+		query.Set("search_path", cfg.Extra.SearchPath)
 	}
 
 	dsn := url.URL{
 		Scheme:   "postgres",
-		User:     url.UserPassword(database.cfg.User, database.cfg.Password),
-		Host:     net.JoinHostPort(database.cfg.Host, fmt.Sprintf("%d", database.cfg.Port)),
-		Path:     database.cfg.Name,
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(host, fmt.Sprintf("%d", port)),
+		Path:     cfg.Name,
 		RawQuery: query.Encode(),
 	}
 
 	return dsn.String()
 }
-
-func (database *postgresDatabase[T]) pingConnection(ctx context.Context, pool *sql.DB) error {
-	var (
-		err error
-	)
-
-	for i := 0; i < database.cfg.Extra.ConnRetry; i++ {
-
-		switch err = pool.PingContext(ctx); err {
-		case nil:
-			return nil
-
-		case context.Canceled, context.DeadlineExceeded:
-			return errors.Wrap(err, "ping database connection timeout exceeded")
-
-		default:
-		}
-	}
-
-	return errors.Wrap(err, "ping database connection failed")
-}
@@ -0,0 +1,290 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cetnfurkan/core/config"
+	"github.com/cetnfurkan/core/mq/common"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultListenerReconnectInterval = 5 * time.Second
+)
+
+type (
+	// Notification is a message delivered by postgres NOTIFY.
+	Notification struct {
+		Channel string
+		Payload string
+	}
+
+	// PostgresListener subscribes to postgres channels on a dedicated
+	// connection and delivers notifications to the channel handlers.
+	PostgresListener struct {
+		cfg               *postgresDatabaseConfig
+		reconnectInterval time.Duration
+		mutex             sync.Mutex
+		handlers          map[string][]common.MessageHandler[*Notification]
+		listening         map[string]bool
+		interrupt         context.CancelFunc
+
+		// callbacks
+		messageHandler common.MessageHandler[*Notification]
+	}
+
+	listenerOption func(*PostgresListener)
+
+	// Execer is common.Execer, so the queries of the database and mq
+	// packages can share a transaction.
+	Execer = common.Execer
+)
+
+// WithListenerMessageHandler sets the handler for channels
+// subscribed without handlers.
+func WithListenerMessageHandler(handler common.MessageHandler[*Notification]) listenerOption {
+	return func(listener *PostgresListener) {
+		listener.messageHandler = handler
+	}
+}
+
+// WithListenerReconnectInterval sets the wait between reconnect attempts.
+// Default is 5 seconds.
+func WithListenerReconnectInterval(interval time.Duration) listenerOption {
+	return func(listener *PostgresListener) {
+		listener.reconnectInterval = interval
+	}
+}
+
+// NewPostgresListener creates a new postgres listener instance.
+//
+// It takes a config instance and returns a new listener instance.
+// The connection is opened by Listen.
+//
+// It will panic if it fails to unmarhal extra config data.
+func NewPostgresListener(cfg *config.Database, opts ...listenerOption) *PostgresListener {
+	listener := &PostgresListener{
+		cfg: &postgresDatabaseConfig{
+			Database: cfg,
+		},
+		reconnectInterval: defaultListenerReconnectInterval,
+		handlers:          make(map[string][]common.MessageHandler[*Notification]),
+		listening:         make(map[string]bool),
+	}
+
+	err := listener.cfg.unmarshalExtra()
+	if err != nil {
+		log.Fatal("failed to decode extra config into struct: ", err)
+	}
+
+	listener.messageHandler = listener.defaultMessageHandler
+
+	for _, opt := range opts {
+		opt(listener)
+	}
+
+	return listener
+}
+
+// Subscribe registers handlers for the channel. It can be called
+// before or while Listen is running.
+func (listener *PostgresListener) Subscribe(channel string, handlers ...common.MessageHandler[*Notification]) {
+	listener.mutex.Lock()
+	defer listener.mutex.Unlock()
+
+	if len(handlers) == 0 {
+		handlers = append(handlers, listener.messageHandler)
+	}
+
+	listener.handlers[channel] = append(listener.handlers[channel], handlers...)
+
+	// Wake up the running listener so it issues LISTEN for the channel.
+	if listener.interrupt != nil && !listener.listening[channel] {
+		listener.interrupt()
+	}
+}
+
+// Listen connects to postgres, listens on the subscribed channels and
+// delivers notifications until the context is done.
+//
+// Lost connections are reopened and the channels are listened again.
+// Notifications sent while disconnected are lost.
+func (listener *PostgresListener) Listen(ctx context.Context) error {
+	var (
+		conn *pgx.Conn
+		err  error
+	)
+
+	defer func() {
+		if conn != nil {
+			conn.Close(context.Background())
+		}
+	}()
+
+	for {
+		if conn == nil {
+			conn, err = listener.connect(ctx)
+			if err != nil {
+				log.Printf("postgres listener failed to connect: %v", err)
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+
+				case <-time.After(listener.reconnectInterval):
+					continue
+				}
+			}
+		}
+
+		err = listener.listen(ctx, conn)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			log.Printf("postgres listener failed to listen: %v", err)
+
+			conn.Close(context.Background())
+			conn = nil
+			continue
+		}
+
+		notification, err := listener.wait(ctx, conn)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// Interrupted by Subscribe, the connection is still usable.
+			if !conn.PgConn().IsClosed() && errors.Is(err, context.Canceled) {
+				continue
+			}
+
+			log.Printf("postgres listener lost connection: %v", err)
+
+			conn.Close(context.Background())
+			conn = nil
+			continue
+		}
+
+		if notification != nil {
+			listener.dispatch(notification)
+		}
+	}
+}
+
+// Notify publishes the payload on the channel using pg_notify.
+//
+// When execer is a transaction, the notification is delivered on commit.
+func Notify(ctx context.Context, execer Execer, channel, payload string) error {
+	_, err := execer.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
+func (listener *PostgresListener) connect(ctx context.Context) (*pgx.Conn, error) {
+	connectCtx, cancel := context.WithTimeout(ctx, listener.connTimeOut())
+	defer cancel()
+
+	conn, err := pgx.Connect(connectCtx, listener.cfg.getDSN(listener.cfg.Host, listener.cfg.Port))
+	if err != nil {
+		return nil, err
+	}
+
+	listener.mutex.Lock()
+	listener.listening = make(map[string]bool)
+	listener.mutex.Unlock()
+
+	return conn, nil
+}
+
+// listen issues LISTEN for the channels that are not listened
+// on the current connection yet.
+func (listener *PostgresListener) listen(ctx context.Context, conn *pgx.Conn) error {
+	listener.mutex.Lock()
+	defer listener.mutex.Unlock()
+
+	for channel := range listener.handlers {
+		if listener.listening[channel] {
+			continue
+		}
+
+		_, err := conn.Exec(ctx, fmt.Sprintf("LISTEN %s", pgx.Identifier{channel}.Sanitize()))
+		if err != nil {
+			return errors.Wrapf(err, "failed to listen channel %s", channel)
+		}
+
+		listener.listening[channel] = true
+	}
+
+	return nil
+}
+
+func (listener *PostgresListener) wait(ctx context.Context, conn *pgx.Conn) (*Notification, error) {
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	listener.mutex.Lock()
+	listener.interrupt = cancel
+	pending := listener.hasPending()
+	listener.mutex.Unlock()
+
+	defer func() {
+		listener.mutex.Lock()
+		listener.interrupt = nil
+		listener.mutex.Unlock()
+	}()
+
+	// A channel was subscribed after the last LISTEN.
+	if pending {
+		return nil, nil
+	}
+
+	notification, err := conn.WaitForNotification(waitCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Notification{
+		Channel: notification.Channel,
+		Payload: notification.Payload,
+	}, nil
+}
+
+func (listener *PostgresListener) hasPending() bool {
+	for channel := range listener.handlers {
+		if !listener.listening[channel] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (listener *PostgresListener) dispatch(notification *Notification) {
+	listener.mutex.Lock()
+	handlers := listener.handlers[notification.Channel]
+	listener.mutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(notification)
+	}
+}
+
+func (listener *PostgresListener) connTimeOut() time.Duration {
+	if listener.cfg.Extra.ConnTimeOut <= 0 {
+		return defaultListenerReconnectInterval
+	}
+
+	return time.Duration(listener.cfg.Extra.ConnTimeOut) * time.Second
+}
+
+func (listener *PostgresListener) defaultMessageHandler(notification *Notification) {
+	fmt.Println(notification.Payload)
+}
@@ -0,0 +1,265 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultElectionInterval = 5 * time.Second
+)
+
+type (
+	// AdvisoryLock is a postgres advisory lock identified by a name.
+	//
+	// Session locks are held on a dedicated connection of the pool until
+	// Unlock is called or the connection is lost. Transaction locks are
+	// released when the transaction ends.
+	AdvisoryLock struct {
+		db    *sql.DB
+		name  string
+		key   int64
+		mutex sync.Mutex
+		conn  *sql.Conn
+	}
+
+	// LeaderElection elects one leader among the replicas
+	// competing for the same lock name.
+	LeaderElection struct {
+		lock     *AdvisoryLock
+		interval time.Duration
+		leader   atomic.Bool
+	}
+
+	leaderElectionOption func(*LeaderElection)
+)
+
+// NewAdvisoryLock creates a new advisory lock instance.
+//
+// It takes a database created by NewPostgresDatabase and the lock name,
+// which is hashed into the advisory lock key.
+//
+// It will panic if the database is not backed by database/sql.
+func NewAdvisoryLock(database Database, name string) *AdvisoryLock {
+	db, err := GetDB(database)
+	if err != nil {
+		log.Fatal("failed to get postgres connection pool: ", err)
+	}
+
+	return newAdvisoryLock(db, name)
+}
+
+func newAdvisoryLock(db *sql.DB, name string) *AdvisoryLock {
+	return &AdvisoryLock{
+		db:   db,
+		name: name,
+		key:  lockKey(name),
+	}
+}
+
+// TryLock acquires the session lock if it is free and reports whether
+// it is acquired. It does not wait for the lock.
+func (lock *AdvisoryLock) TryLock(ctx context.Context) (bool, error) {
+	return lock.acquire(ctx, "SELECT pg_try_advisory_lock($1)")
+}
+
+// Lock blocks until the session lock is acquired. It returns an error
+// if the context is done first, use a context with a timeout to bound the wait.
+func (lock *AdvisoryLock) Lock(ctx context.Context) error {
+	_, err := lock.acquire(ctx, "SELECT true FROM pg_advisory_lock($1)")
+	return err
+}
+
+// Unlock releases the session lock. It does nothing if the lock is not held.
+// If the lock fails to release, the connection is closed instead of
+// being returned to the pool, which releases the lock as well.
+func (lock *AdvisoryLock) Unlock(ctx context.Context) error {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	if lock.conn == nil {
+		return nil
+	}
+
+	conn := lock.conn
+	lock.conn = nil
+
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lock.key)
+	if err != nil {
+		discardConn(conn)
+		return errors.Wrapf(err, "failed to release advisory lock %s", lock.name)
+	}
+
+	conn.Close()
+
+	return nil
+}
+
+// TryLockTx acquires the lock for the transaction if it is free
+// and reports whether it is acquired.
+func (lock *AdvisoryLock) TryLockTx(ctx context.Context, tx *sql.Tx) (bool, error) {
+	var acquired bool
+
+	err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", lock.key).Scan(&acquired)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to acquire advisory lock %s", lock.name)
+	}
+
+	return acquired, nil
+}
+
+// LockTx blocks until the lock is acquired for the transaction.
+func (lock *AdvisoryLock) LockTx(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", lock.key)
+	if err != nil {
+		return errors.Wrapf(err, "failed to acquire advisory lock %s", lock.name)
+	}
+
+	return nil
+}
+
+// Held reports whether the session lock is held and its connection is alive.
+func (lock *AdvisoryLock) Held(ctx context.Context) bool {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	return lock.conn != nil && lock.conn.PingContext(ctx) == nil
+}
+
+func (lock *AdvisoryLock) acquire(ctx context.Context, query string) (bool, error) {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	if lock.conn != nil {
+		return false, coreErrors.ErrLockAlreadyHeld
+	}
+
+	conn, err := lock.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var acquired bool
+
+	err = conn.QueryRowContext(ctx, query, lock.key).Scan(&acquired)
+	if err != nil {
+		conn.Close()
+		return false, errors.Wrapf(err, "failed to acquire advisory lock %s", lock.name)
+	}
+
+	if !acquired {
+		conn.Close()
+		return false, nil
+	}
+
+	lock.conn = conn
+
+	return true, nil
+}
+
+// WithElectionInterval sets how often the lock is tried by a follower and
+// checked by the leader. Default is 5 seconds.
+func WithElectionInterval(interval time.Duration) leaderElectionOption {
+	return func(election *LeaderElection) {
+		election.interval = interval
+	}
+}
+
+// NewLeaderElection creates a new leader election instance.
+//
+// It takes a database created by NewPostgresDatabase and the name
+// of the election, replicas using the same name compete.
+//
+// It will panic if the database is not backed by database/sql.
+func NewLeaderElection(database Database, name string, opts ...leaderElectionOption) *LeaderElection {
+	election := &LeaderElection{
+		lock:     NewAdvisoryLock(database, name),
+		interval: defaultElectionInterval,
+	}
+
+	for _, opt := range opts {
+		opt(election)
+	}
+
+	return election
+}
+
+// Run campaigns for leadership until the context is done and calls lead
+// each time it is elected. The context given to lead is canceled when
+// leadership is lost, Run waits for lead to return before campaigning again.
+func (election *LeaderElection) Run(ctx context.Context, lead func(ctx context.Context)) error {
+	ticker := time.NewTicker(election.interval)
+	defer ticker.Stop()
+
+	for {
+		acquired, err := election.lock.TryLock(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("leader election %s failed to acquire lock: %v", election.lock.name, err)
+		}
+
+		if acquired {
+			election.lead(ctx, ticker, lead)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsLeader reports whether this instance is the leader.
+func (election *LeaderElection) IsLeader() bool {
+	return election.leader.Load()
+}
+
+func (election *LeaderElection) lead(ctx context.Context, ticker *time.Ticker, lead func(ctx context.Context)) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	election.leader.Store(true)
+
+	go func() {
+		defer close(done)
+		lead(leaderCtx)
+	}()
+
+loop:
+	for {
+		select {
+		case <-done:
+			break loop
+
+		case <-ctx.Done():
+			break loop
+
+		case <-ticker.C:
+			if !election.lock.Held(ctx) {
+				log.Printf("leader election %s lost its lock", election.lock.name)
+				break loop
+			}
+		}
+	}
+
+	election.leader.Store(false)
+	cancel()
+	<-done
+
+	unlockCtx, unlockCancel := context.WithTimeout(context.Background(), election.interval)
+	defer unlockCancel()
+
+	err := election.lock.Unlock(unlockCtx)
+	if err != nil {
+		log.Printf("leader election %s failed to release lock: %v", election.lock.name, err)
+	}
+}
@@ -0,0 +1,452 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+const (
+	ReplicaPolicyRoundRobin       = "roundRobin"
+	ReplicaPolicyLeastConnections = "leastConnections"
+
+	defaultReplicaHealthCheckInterval = 5 * time.Second
+)
+
+var (
+	lockingReadPattern = regexp.MustCompile(`(?i)\bFOR\s+(UPDATE|SHARE|NO\s+KEY\s+UPDATE|KEY\s+SHARE)\b`)
+)
+
+type (
+	primaryContextKey struct{}
+
+	postgresReplica struct {
+		name    string
+		db      *sql.DB
+		healthy atomic.Bool
+	}
+
+	// postgresReplicaRouter sends read only queries to healthy replicas
+	// and everything else, including transactions, to the primary.
+	//
+	// The routing happens below database/sql so the ent driver keeps
+	// working on a plain *sql.DB.
+	postgresReplicaRouter struct {
+		primary   *sql.DB
+		replicas  []*postgresReplica
+		policy    string
+		next      atomic.Uint64
+		db        *sql.DB
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+
+	replicaConnector struct {
+		router *postgresReplicaRouter
+	}
+
+	replicaDriver struct{}
+
+	replicaConn struct {
+		router *postgresReplicaRouter
+		tx     *sql.Tx
+	}
+
+	replicaTx struct {
+		conn *replicaConn
+	}
+
+	replicaStmt struct {
+		conn  *replicaConn
+		query string
+	}
+
+	replicaRows struct {
+		rows    *sql.Rows
+		columns []string
+	}
+)
+
+// Primary returns a context that forces queries to run on the primary,
+// e.g. to read your own writes right after a commit.
+func Primary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+func isPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey{}).(bool)
+	return primary
+}
+
+// isReadQuery reports whether the query can be served by a replica.
+// Only plain SELECT statements without row locks qualify.
+//
+// Functions with side effects are not detected, so SELECTs calling them,
+// e.g. nextval, pg_advisory_lock or pg_notify, must run with Primary.
+func isReadQuery(query string) bool {
+	query = strings.TrimSpace(query)
+	if len(query) < 6 || !strings.EqualFold(query[:6], "SELECT") {
+		return false
+	}
+
+	return !lockingReadPattern.MatchString(query)
+}
+
+func newPostgresReplica(name string, db *sql.DB) *postgresReplica {
+	replica := &postgresReplica{
+		name: name,
+		db:   db,
+	}
+
+	replica.healthy.Store(true)
+
+	return replica
+}
+
+func newPostgresReplicaRouter(primary *sql.DB, replicas []*postgresReplica, policy string, interval time.Duration) *postgresReplicaRouter {
+	if interval <= 0 {
+		interval = defaultReplicaHealthCheckInterval
+	}
+
+	router := &postgresReplicaRouter{
+		primary:  primary,
+		replicas: replicas,
+		policy:   policy,
+		done:     make(chan struct{}),
+	}
+
+	router.db = sql.OpenDB(&replicaConnector{router: router})
+
+	// Connections of the router are only handles over the primary
+	// and replica pools, keeping them idle is cheap.
+	router.db.SetMaxIdleConns(primary.Stats().MaxOpenConnections + 1)
+
+	go router.healthCheck(interval)
+
+	return router
+}
+
+// DB returns the routing pool.
+func (router *postgresReplicaRouter) DB() *sql.DB {
+	return router.db
+}
+
+func (router *postgresReplicaRouter) pick(ctx context.Context, query string) (*sql.DB, *postgresReplica) {
+	if isPrimary(ctx) || !isReadQuery(query) {
+		return router.primary, nil
+	}
+
+	healthy := make([]*postgresReplica, 0, len(router.replicas))
+	for _, replica := range router.replicas {
+		if replica.healthy.Load() {
+			healthy = append(healthy, replica)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return router.primary, nil
+	}
+
+	var replica *postgresReplica
+
+	switch router.policy {
+	case ReplicaPolicyLeastConnections:
+		for _, candidate := range healthy {
+			if replica == nil || candidate.db.Stats().InUse < replica.db.Stats().InUse {
+				replica = candidate
+			}
+		}
+
+	default:
+		replica = healthy[router.next.Add(1)%uint64(len(healthy))]
+	}
+
+	return replica.db, replica
+}
+
+// Close stops the health check and closes the routing and replica pools.
+// The primary pool is left open. Closing it again does nothing.
+func (router *postgresReplicaRouter) Close() {
+	router.closeOnce.Do(func() {
+		close(router.done)
+
+		router.db.Close()
+		closeReplicas(router.replicas)
+	})
+}
+
+func (router *postgresReplicaRouter) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-router.done:
+			return
+
+		case <-ticker.C:
+		}
+
+		for _, replica := range router.replicas {
+			router.check(replica, interval)
+		}
+	}
+}
+
+// check pings the replica and ejects or re-adds it depending on the result.
+func (router *postgresReplicaRouter) check(replica *postgresReplica, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := replica.db.PingContext(ctx)
+	healthy := err == nil
+
+	if replica.healthy.Swap(healthy) != healthy {
+		if healthy {
+			log.Printf("postgres replica %s is healthy again", replica.name)
+		} else {
+			log.Printf("postgres replica %s is unhealthy: %v", replica.name, err)
+		}
+	}
+}
+
+// eject marks the replica unhealthy until the health check re-adds it.
+func (router *postgresReplicaRouter) eject(replica *postgresReplica, err error) {
+	if replica.healthy.Swap(false) {
+		log.Printf("postgres replica %s is unhealthy: %v", replica.name, err)
+	}
+}
+
+// isConnectionError reports whether the error is caused by the connection
+// to the server rather than the query.
+func isConnectionError(err error) bool {
+	var (
+		connectErr *pgconn.ConnectError
+		netErr     net.Error
+	)
+
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &connectErr) ||
+		errors.As(err, &netErr)
+}
+
+func closeReplicas(replicas []*postgresReplica) {
+	for _, replica := range replicas {
+		replica.db.Close()
+	}
+}
+
+func (connector *replicaConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &replicaConn{router: connector.router}, nil
+}
+
+func (connector *replicaConnector) Driver() driver.Driver {
+	return replicaDriver{}
+}
+
+func (replicaDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("replica driver can only be opened through its connector")
+}
+
+func (conn *replicaConn) Prepare(query string) (driver.Stmt, error) {
+	return &replicaStmt{conn: conn, query: query}, nil
+}
+
+func (conn *replicaConn) Close() error {
+	if conn.tx == nil {
+		return nil
+	}
+
+	err := conn.tx.Rollback()
+	conn.tx = nil
+
+	return err
+}
+
+func (conn *replicaConn) Begin() (driver.Tx, error) {
+	return conn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (conn *replicaConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := conn.router.primary.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.IsolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	conn.tx = tx
+
+	return &replicaTx{conn: conn}, nil
+}
+
+func (conn *replicaConn) Ping(ctx context.Context) error {
+	return conn.router.primary.PingContext(ctx)
+}
+
+// CheckNamedValue passes arguments through unchanged, the underlying
+// pgx pools convert them.
+func (conn *replicaConn) CheckNamedValue(value *driver.NamedValue) error {
+	return nil
+}
+
+func (conn *replicaConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if conn.tx != nil {
+		return conn.tx.ExecContext(ctx, query, namedValues(args)...)
+	}
+
+	return conn.router.primary.ExecContext(ctx, query, namedValues(args)...)
+}
+
+func (conn *replicaConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	if conn.tx != nil {
+		rows, err = conn.tx.QueryContext(ctx, query, namedValues(args)...)
+		if err != nil {
+			return nil, err
+		}
+
+		return newReplicaRows(rows)
+	}
+
+	db, replica := conn.router.pick(ctx, query)
+
+	// Replicas failing with connection errors are ejected and the query
+	// is retried on the primary, other errors are caused by the query.
+	rows, err = db.QueryContext(ctx, query, namedValues(args)...)
+	if err != nil && replica != nil && ctx.Err() == nil && isConnectionError(err) {
+		conn.router.eject(replica, err)
+		rows, err = conn.router.primary.QueryContext(ctx, query, namedValues(args)...)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newReplicaRows(rows)
+}
+
+func (tx *replicaTx) Commit() error {
+	err := tx.conn.tx.Commit()
+	tx.conn.tx = nil
+
+	return err
+}
+
+func (tx *replicaTx) Rollback() error {
+	err := tx.conn.tx.Rollback()
+	tx.conn.tx = nil
+
+	return err
+}
+
+func (stmt *replicaStmt) Close() error {
+	return nil
+}
+
+func (stmt *replicaStmt) NumInput() int {
+	return -1
+}
+
+func (stmt *replicaStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return stmt.ExecContext(context.Background(), valuesToNamed(args))
+}
+
+func (stmt *replicaStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return stmt.QueryContext(context.Background(), valuesToNamed(args))
+}
+
+func (stmt *replicaStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return stmt.conn.ExecContext(ctx, stmt.query, args)
+}
+
+func (stmt *replicaStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return stmt.conn.QueryContext(ctx, stmt.query, args)
+}
+
+func newReplicaRows(rows *sql.Rows) (driver.Rows, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	return &replicaRows{rows: rows, columns: columns}, nil
+}
+
+func (rows *replicaRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *replicaRows) Close() error {
+	return rows.rows.Close()
+}
+
+func (rows *replicaRows) Next(dest []driver.Value) error {
+	if !rows.rows.Next() {
+		err := rows.rows.Err()
+		if err != nil {
+			return err
+		}
+
+		return io.EOF
+	}
+
+	values := make([]any, len(dest))
+	pointers := make([]any, len(dest))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	err := rows.rows.Scan(pointers...)
+	if err != nil {
+		return err
+	}
+
+	for i := range dest {
+		dest[i] = values[i]
+	}
+
+	return nil
+}
+
+func namedValues(args []driver.NamedValue) []any {
+	values := make([]any, len(args))
+
+	for i, arg := range args {
+		if arg.Name != "" {
+			values[i] = sql.Named(arg.Name, arg.Value)
+		} else {
+			values[i] = arg.Value
+		}
+	}
+
+	return values
+}
+
+func valuesToNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+
+	return named
+}
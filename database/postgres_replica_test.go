@@ -0,0 +1,278 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	errTestQuery = errors.New("syntax error")
+)
+
+type (
+	// testServer answers every query with its name, or refuses
+	// connections while it is down.
+	testServer struct {
+		name string
+		down atomic.Bool
+	}
+
+	testServerConn struct {
+		server *testServer
+	}
+
+	testServerTx struct{}
+
+	testServerRows struct {
+		name string
+		done bool
+	}
+)
+
+func (server *testServer) Connect(context.Context) (driver.Conn, error) {
+	if server.down.Load() {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+
+	return &testServerConn{server: server}, nil
+}
+
+func (server *testServer) Driver() driver.Driver {
+	return nil
+}
+
+func (conn *testServerConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (conn *testServerConn) Close() error {
+	return nil
+}
+
+func (conn *testServerConn) Begin() (driver.Tx, error) {
+	return testServerTx{}, nil
+}
+
+func (conn *testServerConn) Ping(ctx context.Context) error {
+	if conn.server.down.Load() {
+		return driver.ErrBadConn
+	}
+
+	return nil
+}
+
+func (conn *testServerConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if conn.server.down.Load() {
+		return nil, driver.ErrBadConn
+	}
+
+	if query == "SELECT bad" {
+		return nil, errTestQuery
+	}
+
+	return &testServerRows{name: conn.server.name}, nil
+}
+
+func (testServerTx) Commit() error {
+	return nil
+}
+
+func (testServerTx) Rollback() error {
+	return nil
+}
+
+func (rows *testServerRows) Columns() []string {
+	return []string{"server"}
+}
+
+func (rows *testServerRows) Close() error {
+	return nil
+}
+
+func (rows *testServerRows) Next(dest []driver.Value) error {
+	if rows.done {
+		return io.EOF
+	}
+
+	rows.done = true
+	dest[0] = rows.name
+
+	return nil
+}
+
+// newTestReplicaRouter returns a router over a primary and two replicas
+// without the periodic health check.
+func newTestReplicaRouter(t *testing.T, policy string) (*postgresReplicaRouter, []*testServer) {
+	t.Helper()
+
+	servers := []*testServer{{name: "primary"}, {name: "replica1"}, {name: "replica2"}}
+
+	replicas := []*postgresReplica{
+		newPostgresReplica(servers[1].name, sql.OpenDB(servers[1])),
+		newPostgresReplica(servers[2].name, sql.OpenDB(servers[2])),
+	}
+
+	router := newPostgresReplicaRouter(sql.OpenDB(servers[0]), replicas, policy, time.Hour)
+	t.Cleanup(router.Close)
+
+	return router, servers
+}
+
+// servedBy returns the name of the server that ran the query.
+func servedBy(t *testing.T, ctx context.Context, db *sql.DB, query string) string {
+	t.Helper()
+
+	var name string
+
+	err := db.QueryRowContext(ctx, query).Scan(&name)
+	if err != nil {
+		t.Fatalf("QueryRowContext(%q) error = %v", query, err)
+	}
+
+	return name
+}
+
+func TestIsReadQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: "SELECT * FROM users", want: true},
+		{query: "  select id FROM users WHERE name = $1", want: true},
+		{query: "SELECT * FROM users FOR UPDATE"},
+		{query: "SELECT * FROM users FOR NO KEY UPDATE"},
+		{query: "select * from users for share"},
+		{query: "INSERT INTO users (name) VALUES ($1) RETURNING id"},
+		{query: "UPDATE users SET name = $1"},
+		{query: "WITH deleted AS (DELETE FROM users RETURNING id) SELECT * FROM deleted"},
+		{query: "SELEC"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			if got := isReadQuery(test.query); got != test.want {
+				t.Errorf("isReadQuery() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	router, _ := newTestReplicaRouter(t, ReplicaPolicyRoundRobin)
+	ctx := context.Background()
+
+	first := servedBy(t, ctx, router.DB(), "SELECT 1")
+	second := servedBy(t, ctx, router.DB(), "SELECT 1")
+
+	if first == "primary" || second == "primary" || first == second {
+		t.Errorf("reads are served by %s and %s, want both replicas in turn", first, second)
+	}
+
+	if got := servedBy(t, ctx, router.DB(), "INSERT INTO users DEFAULT VALUES RETURNING id"); got != "primary" {
+		t.Errorf("write is served by %s, want primary", got)
+	}
+
+	if got := servedBy(t, ctx, router.DB(), "SELECT 1 FOR UPDATE"); got != "primary" {
+		t.Errorf("locking read is served by %s, want primary", got)
+	}
+
+	if got := servedBy(t, Primary(ctx), router.DB(), "SELECT 1"); got != "primary" {
+		t.Errorf("read with Primary is served by %s, want primary", got)
+	}
+
+	tx, err := router.DB().BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	var name string
+
+	err = tx.QueryRowContext(ctx, "SELECT 1").Scan(&name)
+	if err != nil {
+		t.Fatalf("QueryRowContext() in transaction error = %v", err)
+	}
+
+	if name != "primary" {
+		t.Errorf("read in transaction is served by %s, want primary", name)
+	}
+}
+
+func TestReplicaRoutingLeastConnections(t *testing.T) {
+	router, _ := newTestReplicaRouter(t, ReplicaPolicyLeastConnections)
+	ctx := context.Background()
+
+	rows, err := router.replicas[0].db.QueryContext(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("QueryContext() error = %v", err)
+	}
+	defer rows.Close()
+
+	if got := servedBy(t, ctx, router.DB(), "SELECT 1"); got != "replica2" {
+		t.Errorf("read is served by %s, want the replica with fewer connections in use", got)
+	}
+}
+
+func TestReplicaEjectAndReadd(t *testing.T) {
+	router, servers := newTestReplicaRouter(t, ReplicaPolicyRoundRobin)
+	ctx := context.Background()
+
+	servers[1].down.Store(true)
+	servers[2].down.Store(true)
+
+	// A read on a replica that is down falls back to the primary.
+	for i := 0; i < 2; i++ {
+		if got := servedBy(t, ctx, router.DB(), "SELECT 1"); got != "primary" {
+			t.Errorf("read with replicas down is served by %s, want primary", got)
+		}
+	}
+
+	for _, replica := range router.replicas {
+		if replica.healthy.Load() {
+			t.Errorf("replica %s is not ejected after a connection error", replica.name)
+		}
+	}
+
+	servers[1].down.Store(false)
+
+	for _, replica := range router.replicas {
+		router.check(replica, time.Second)
+	}
+
+	if !router.replicas[0].healthy.Load() || router.replicas[1].healthy.Load() {
+		t.Fatal("check() did not re-add the replica that is up again only")
+	}
+
+	if got := servedBy(t, ctx, router.DB(), "SELECT 1"); got != "replica1" {
+		t.Errorf("read is served by %s, want the re-added replica", got)
+	}
+}
+
+func TestReplicaKeptOnQueryErrors(t *testing.T) {
+	router, _ := newTestReplicaRouter(t, ReplicaPolicyRoundRobin)
+
+	_, err := router.DB().QueryContext(context.Background(), "SELECT bad")
+	if !errors.Is(err, errTestQuery) {
+		t.Fatalf("QueryContext() error = %v, want %v", err, errTestQuery)
+	}
+
+	for _, replica := range router.replicas {
+		if !replica.healthy.Load() {
+			t.Errorf("replica %s is ejected after a query error", replica.name)
+		}
+	}
+}
+
+func TestReplicaRouterCloseTwice(t *testing.T) {
+	router, _ := newTestReplicaRouter(t, ReplicaPolicyRoundRobin)
+
+	router.Close()
+	router.Close()
+}
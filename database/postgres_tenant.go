@@ -0,0 +1,409 @@
+package database
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cetnfurkan/core/config"
+	coreErrors "github.com/cetnfurkan/core/errors"
+	"github.com/cetnfurkan/core/tenant"
+
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/pkg/errors"
+)
+
+const (
+	TenantModeSchema   = "schema"
+	TenantModeDatabase = "database"
+
+	defaultMaxTenants        = 100
+	defaultTenantIdleTimeout = 10 * time.Minute
+)
+
+var (
+	tenantNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// reservedTenantNames are the schemas and databases of postgres itself,
+	// names starting with pg_ are reserved as well.
+	reservedTenantNames = map[string]bool{
+		"public":             true,
+		"information_schema": true,
+		"postgres":           true,
+		"template0":          true,
+		"template1":          true,
+	}
+)
+
+type (
+	// postgresTenantDatabase routes to a client per tenant, resolved from
+	// the context. Tenants are opened on first use and evicted when idle.
+	postgresTenantDatabase[T any] struct {
+		cfg          *postgresDatabaseConfig
+		createClient func(*entsql.Driver) *T
+		openDatabase func(id string) (*postgresDatabase[T], error)
+		options      *options[T]
+		maxTenants   int
+		idleTimeout  time.Duration
+
+		mutex   sync.Mutex
+		tenants map[string]*postgresTenant[T]
+		closed  bool
+		done    chan struct{}
+	}
+
+	// postgresTenant is closed once it is evicted and no longer
+	// used, refs and evicted are guarded by the mutex of the database.
+	postgresTenant[T any] struct {
+		database *postgresDatabase[T]
+		ready    chan struct{}
+		err      error
+		lastUsed atomic.Int64
+		refs     int
+		evicted  bool
+	}
+
+	tenantClienter[T any] interface {
+		Client(ctx context.Context) (*T, func(), error)
+	}
+)
+
+// WithTenantName sets how a tenant is mapped to its schema or database
+// name. By default the tenant is used as is and must be a plain identifier
+// that is not a schema or database of postgres itself, e.g. public.
+func WithTenantName[T any](name func(tenant string) (string, error)) option[T] {
+	return func(opts *options[T]) error {
+		opts.tenantName = name
+		return nil
+	}
+}
+
+// NewPostgresTenantDatabase creates a new multi-tenant postgres database instance.
+//
+// It takes a config instance and returns a new database interface instance.
+// The tenant is read from the context set by tenant.NewContext,
+// use GetTenantClient to get the client of the tenant.
+//
+// When tenantMode is schema, each tenant has a pool on the configured
+// database with search_path set to its schema. When tenantMode is database,
+// each tenant has a pool on its own database. The schema or database
+// of a tenant must exist.
+//
+// Pools are created on first use, at most maxTenants are kept and the ones
+// idle for tenantIdleTimeout seconds are evicted. Evicted pools are closed
+// once the clients returned for them are released.
+// Replicas are not used. Migrations and callbacks run for each tenant
+// when its pool is created. Use Close to close the pools.
+//
+// It will panic
+// if it fails to unmarhal extra config data or
+// if tenantMode is not schema or database.
+func NewPostgresTenantDatabase[T any](cfg *config.Database, createClient func(*entsql.Driver) *T, opts ...option[T]) Database {
+	database := &postgresTenantDatabase[T]{
+		cfg: &postgresDatabaseConfig{
+			Database: cfg,
+		},
+		createClient: createClient,
+		tenants:      make(map[string]*postgresTenant[T]),
+		done:         make(chan struct{}),
+	}
+
+	database.openDatabase = database.openTenant
+	database.UnmarshalExtra()
+
+	if database.cfg.Extra.TenantMode != TenantModeSchema && database.cfg.Extra.TenantMode != TenantModeDatabase {
+		log.Fatalf("invalid postgres tenant mode: %q", database.cfg.Extra.TenantMode)
+	}
+
+	database.options = newOptions(opts)
+	if database.options.tenantName == nil {
+		database.options.tenantName = defaultTenantName
+	}
+
+	database.maxTenants = database.cfg.Extra.MaxTenants
+	if database.maxTenants <= 0 {
+		database.maxTenants = defaultMaxTenants
+	}
+
+	database.idleTimeout = time.Duration(database.cfg.Extra.TenantIdleTimeout) * time.Second
+	if database.idleTimeout <= 0 {
+		database.idleTimeout = defaultTenantIdleTimeout
+	}
+
+	go database.evictIdle()
+
+	return database
+}
+
+// GetTenantClient returns the client of the tenant in the context and
+// a func to release it. It returns an error if the database is not
+// multi-tenant.
+//
+// The pool of the tenant is not closed before the client is released,
+// so release must be called once the client is no longer used, e.g.
+//
+//	client, release, err := database.GetTenantClient[ent.Client](ctx, db)
+//	if err != nil {
+//		return err
+//	}
+//	defer release()
+//
+// A pool whose clients are never released is never closed.
+func GetTenantClient[T any](ctx context.Context, database Database) (*T, func(), error) {
+	clienter, ok := database.(tenantClienter[T])
+	if !ok {
+		return nil, nil, coreErrors.ErrTenantNotFound
+	}
+
+	return clienter.Client(ctx)
+}
+
+// Get returns the tenant database itself, clients are per tenant.
+func (database *postgresTenantDatabase[T]) Get() any {
+	return database
+}
+
+func (database *postgresTenantDatabase[T]) UnmarshalExtra() {
+	err := database.cfg.unmarshalExtra()
+	if err != nil {
+		log.Fatal("failed to decode extra config into struct: ", err)
+	}
+}
+
+// Client returns the client of the tenant in the context and a func to
+// release it, opening the tenant pool if it is not open yet. The pool is
+// kept open until the client is released, see GetTenantClient.
+func (database *postgresTenantDatabase[T]) Client(ctx context.Context) (*T, func(), error) {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, nil, coreErrors.ErrTenantNotFound
+	}
+
+	database.mutex.Lock()
+
+	if database.closed {
+		database.mutex.Unlock()
+		return nil, nil, coreErrors.ErrDatabaseClosed
+	}
+
+	entry, ok := database.tenants[id]
+	if !ok {
+		if len(database.tenants) >= database.maxTenants {
+			database.evictLeastRecentlyUsed()
+		}
+
+		entry = &postgresTenant[T]{ready: make(chan struct{})}
+		database.tenants[id] = entry
+	}
+
+	entry.lastUsed.Store(time.Now().UnixNano())
+	entry.refs++
+
+	database.mutex.Unlock()
+
+	var once sync.Once
+
+	release := func() {
+		once.Do(func() {
+			database.release(entry)
+		})
+	}
+
+	if !ok {
+		database.open(id, entry)
+	}
+
+	select {
+	case <-entry.ready:
+
+	case <-ctx.Done():
+		release()
+		return nil, nil, ctx.Err()
+	}
+
+	if entry.err != nil {
+		release()
+		return nil, nil, entry.err
+	}
+
+	return entry.database.client, release, nil
+}
+
+// open opens the tenant and removes it on failure so the next call retries.
+// Tenants opened after Close are evicted right away.
+func (database *postgresTenantDatabase[T]) open(id string, entry *postgresTenant[T]) {
+	entry.database, entry.err = database.openDatabase(id)
+	close(entry.ready)
+
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+
+	if database.tenants[id] != entry {
+		return
+	}
+
+	switch {
+	case entry.err != nil:
+		delete(database.tenants, id)
+
+	case database.closed:
+		database.evict(id, entry)
+	}
+}
+
+func (database *postgresTenantDatabase[T]) openTenant(id string) (*postgresDatabase[T], error) {
+	name, err := database.options.tenantName(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *database.cfg.Database
+	extra := database.cfg.Extra
+	extra.Replicas = nil
+
+	switch extra.TenantMode {
+	case TenantModeSchema:
+		extra.SearchPath = name
+
+	case TenantModeDatabase:
+		cfg.Name = name
+	}
+
+	tenantDatabase := &postgresDatabase[T]{
+		cfg: &postgresDatabaseConfig{
+			Database: &cfg,
+			Extra:    extra,
+		},
+	}
+
+	err = tenantDatabase.open(database.createClient, database.options)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open tenant %s", id)
+	}
+
+	err = database.options.runCallbacks(tenantDatabase.client)
+	if err != nil {
+		tenantDatabase.close()
+		return nil, errors.Wrapf(err, "failed to apply option to tenant %s", id)
+	}
+
+	return tenantDatabase, nil
+}
+
+// evictLeastRecentlyUsed evicts the tenant used least recently. Tenants
+// still opening are in use by their opener, so they are closed once it
+// releases them. It must be called with the mutex held.
+func (database *postgresTenantDatabase[T]) evictLeastRecentlyUsed() {
+	var (
+		evictID    string
+		evictEntry *postgresTenant[T]
+	)
+
+	for id, entry := range database.tenants {
+		if evictEntry == nil || entry.lastUsed.Load() < evictEntry.lastUsed.Load() {
+			evictID, evictEntry = id, entry
+		}
+	}
+
+	if evictEntry != nil {
+		database.evict(evictID, evictEntry)
+	}
+}
+
+func (database *postgresTenantDatabase[T]) evictIdle() {
+	ticker := time.NewTicker(database.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-database.done:
+			return
+
+		case <-ticker.C:
+		}
+
+		deadline := time.Now().Add(-database.idleTimeout).UnixNano()
+
+		database.mutex.Lock()
+		for id, entry := range database.tenants {
+			if entry.isOpen() && entry.lastUsed.Load() < deadline {
+				database.evict(id, entry)
+			}
+		}
+		database.mutex.Unlock()
+	}
+}
+
+// evict removes the tenant and closes it unless it is in use,
+// otherwise release closes it. It must be called with the mutex held.
+func (database *postgresTenantDatabase[T]) evict(id string, entry *postgresTenant[T]) {
+	delete(database.tenants, id)
+	entry.evicted = true
+
+	if entry.refs == 0 {
+		entry.database.close()
+	}
+}
+
+// release is called when a client is released,
+// it closes the tenant if it is evicted and no longer used.
+func (database *postgresTenantDatabase[T]) release(entry *postgresTenant[T]) {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+
+	entry.refs--
+
+	if entry.evicted && entry.refs == 0 && entry.isOpen() {
+		entry.database.close()
+	}
+}
+
+// Close stops evicting idle tenants and closes the tenant pools.
+// Pools in use are closed once their clients are released.
+func (database *postgresTenantDatabase[T]) Close() error {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+
+	if database.closed {
+		return nil
+	}
+
+	database.closed = true
+	close(database.done)
+
+	for id, entry := range database.tenants {
+		if entry.isOpen() {
+			database.evict(id, entry)
+		}
+	}
+
+	return nil
+}
+
+// isOpen reports whether the tenant is opened successfully.
+func (entry *postgresTenant[T]) isOpen() bool {
+	select {
+	case <-entry.ready:
+		return entry.err == nil
+
+	default:
+		return false
+	}
+}
+
+func defaultTenantName(tenant string) (string, error) {
+	if !tenantNamePattern.MatchString(tenant) {
+		return "", coreErrors.ErrInvalidTenant
+	}
+
+	name := strings.ToLower(tenant)
+	if reservedTenantNames[name] || strings.HasPrefix(name, "pg_") {
+		return "", coreErrors.ErrInvalidTenant
+	}
+
+	return tenant, nil
+}
@@ -0,0 +1,293 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+	"github.com/cetnfurkan/core/tenant"
+)
+
+type (
+	testTenantClient struct {
+		tenant string
+	}
+
+	// testConnector fails to connect, the tests only use the pools to see
+	// whether they are closed.
+	testConnector struct{}
+)
+
+func (testConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, driver.ErrBadConn
+}
+
+func (testConnector) Driver() driver.Driver {
+	return nil
+}
+
+// newTestTenantDatabase returns a tenant database whose tenants are opened
+// by open, or right away when open is nil.
+func newTestTenantDatabase(maxTenants int, open func(id string) error) *postgresTenantDatabase[testTenantClient] {
+	database := &postgresTenantDatabase[testTenantClient]{
+		maxTenants: maxTenants,
+		tenants:    make(map[string]*postgresTenant[testTenantClient]),
+		done:       make(chan struct{}),
+	}
+
+	database.openDatabase = func(id string) (*postgresDatabase[testTenantClient], error) {
+		if open != nil {
+			err := open(id)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &postgresDatabase[testTenantClient]{
+			pool:   sql.OpenDB(testConnector{}),
+			client: &testTenantClient{tenant: id},
+		}, nil
+	}
+
+	return database
+}
+
+// tenantClient returns the client of the tenant and the pool it is on.
+func tenantClient(t *testing.T, database *postgresTenantDatabase[testTenantClient], id string) (*testTenantClient, *sql.DB, func()) {
+	t.Helper()
+
+	client, release, err := database.Client(tenant.NewContext(context.Background(), id))
+	if err != nil {
+		t.Fatalf("Client(%q) error = %v", id, err)
+	}
+
+	database.mutex.Lock()
+	pool := database.tenants[id].database.pool
+	database.mutex.Unlock()
+
+	// Tenants are evicted by the time they were used last.
+	time.Sleep(time.Millisecond)
+
+	return client, pool, release
+}
+
+func isClosed(pool *sql.DB) bool {
+	return pool.PingContext(context.Background()).Error() == "sql: database is closed"
+}
+
+func TestTenantClientRouting(t *testing.T) {
+	database := newTestTenantDatabase(10, nil)
+	defer database.Close()
+
+	acme, _, release := tenantClient(t, database, "acme")
+	defer release()
+
+	other, _, release := tenantClient(t, database, "other")
+	defer release()
+
+	again, _, release := tenantClient(t, database, "acme")
+	defer release()
+
+	if acme.tenant != "acme" || other.tenant != "other" {
+		t.Errorf("Client() tenants = %q, %q, want %q, %q", acme.tenant, other.tenant, "acme", "other")
+	}
+
+	if again != acme {
+		t.Error("Client() opened a second client for the same tenant")
+	}
+
+	_, _, err := database.Client(context.Background())
+	if !errors.Is(err, coreErrors.ErrTenantNotFound) {
+		t.Errorf("Client() without tenant error = %v, want %v", err, coreErrors.ErrTenantNotFound)
+	}
+
+	_, _, err = GetTenantClient[testTenantClient](context.Background(), &postgresDatabase[testTenantClient]{})
+	if !errors.Is(err, coreErrors.ErrTenantNotFound) {
+		t.Errorf("GetTenantClient() of a single tenant database error = %v, want %v", err, coreErrors.ErrTenantNotFound)
+	}
+}
+
+func TestTenantClientRetriesFailedOpen(t *testing.T) {
+	errOpen := errors.New("open failed")
+	fail := true
+
+	database := newTestTenantDatabase(10, func(id string) error {
+		if fail {
+			return errOpen
+		}
+
+		return nil
+	})
+	defer database.Close()
+
+	_, _, err := database.Client(tenant.NewContext(context.Background(), "acme"))
+	if !errors.Is(err, errOpen) {
+		t.Fatalf("Client() error = %v, want %v", err, errOpen)
+	}
+
+	fail = false
+
+	_, _, release := tenantClient(t, database, "acme")
+	release()
+}
+
+func TestTenantEviction(t *testing.T) {
+	database := newTestTenantDatabase(2, nil)
+	defer database.Close()
+
+	_, first, release := tenantClient(t, database, "first")
+	release()
+
+	_, second, releaseSecond := tenantClient(t, database, "second")
+	_, _, releaseThird := tenantClient(t, database, "third")
+	defer releaseThird()
+
+	if !isClosed(first) {
+		t.Error("Client() kept the least recently used tenant open")
+	}
+
+	// The second tenant is in use, so it is closed once it is released.
+	_, _, releaseFourth := tenantClient(t, database, "fourth")
+	defer releaseFourth()
+
+	if isClosed(second) {
+		t.Fatal("Client() closed a tenant in use")
+	}
+
+	releaseSecond()
+	releaseSecond()
+
+	if !isClosed(second) {
+		t.Error("release() kept an evicted tenant open")
+	}
+
+	if len(database.tenants) != 2 {
+		t.Errorf("Client() kept %d tenants, want 2", len(database.tenants))
+	}
+}
+
+func TestTenantEvictionCountsOpeningTenants(t *testing.T) {
+	opening := make(chan struct{})
+	unblock := make(chan struct{})
+
+	database := newTestTenantDatabase(1, func(id string) error {
+		if id == "slow" {
+			close(opening)
+			<-unblock
+		}
+
+		return nil
+	})
+	defer database.Close()
+
+	slow := make(chan func())
+
+	go func() {
+		_, release, err := database.Client(tenant.NewContext(context.Background(), "slow"))
+		if err != nil {
+			t.Errorf("Client() error = %v", err)
+		}
+
+		slow <- release
+	}()
+
+	<-opening
+
+	_, _, release := tenantClient(t, database, "fast")
+	defer release()
+
+	database.mutex.Lock()
+	count := len(database.tenants)
+	database.mutex.Unlock()
+
+	if count != 1 {
+		t.Errorf("Client() kept %d tenants, want 1", count)
+	}
+
+	close(unblock)
+
+	releaseSlow := <-slow
+	if releaseSlow == nil {
+		return
+	}
+
+	database.mutex.Lock()
+	_, ok := database.tenants["slow"]
+	database.mutex.Unlock()
+
+	if ok {
+		t.Error("Client() kept an opening tenant evicted by another")
+	}
+
+	releaseSlow()
+}
+
+func TestTenantDatabaseClose(t *testing.T) {
+	database := newTestTenantDatabase(10, nil)
+
+	_, pool, release := tenantClient(t, database, "acme")
+
+	err := database.Close()
+	if err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if isClosed(pool) {
+		t.Fatal("Close() closed a tenant in use")
+	}
+
+	release()
+
+	if !isClosed(pool) {
+		t.Error("release() kept a tenant open after Close")
+	}
+
+	_, _, err = database.Client(tenant.NewContext(context.Background(), "acme"))
+	if !errors.Is(err, coreErrors.ErrDatabaseClosed) {
+		t.Errorf("Client() after Close error = %v, want %v", err, coreErrors.ErrDatabaseClosed)
+	}
+
+	err = database.Close()
+	if err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestDefaultTenantName(t *testing.T) {
+	tests := []struct {
+		tenant  string
+		wantErr bool
+	}{
+		{tenant: "acme"},
+		{tenant: "acme_2"},
+		{tenant: "", wantErr: true},
+		{tenant: "acme;drop", wantErr: true},
+		{tenant: "2acme", wantErr: true},
+		{tenant: "public", wantErr: true},
+		{tenant: "PUBLIC", wantErr: true},
+		{tenant: "information_schema", wantErr: true},
+		{tenant: "pg_catalog", wantErr: true},
+		{tenant: "template1", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.tenant, func(t *testing.T) {
+			name, err := defaultTenantName(test.tenant)
+			if test.wantErr {
+				if !errors.Is(err, coreErrors.ErrInvalidTenant) {
+					t.Errorf("defaultTenantName() error = %v, want %v", err, coreErrors.ErrInvalidTenant)
+				}
+
+				return
+			}
+
+			if err != nil || name != test.tenant {
+				t.Errorf("defaultTenantName() = %q, %v, want %q", name, err, test.tenant)
+			}
+		})
+	}
+}
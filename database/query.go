@@ -0,0 +1,138 @@
+package database
+
+import (
+	"github.com/cetnfurkan/core/http"
+
+	entsql "entgo.io/ent/dialect/sql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// SortFilter applies the sort and filters parsed by http.Paginate.
+	//
+	// Columns maps the fields of the query params to columns,
+	// fields without a column are used as column names.
+	SortFilter struct {
+		Columns map[string]string
+	}
+)
+
+// Ent returns an ent predicate that filters the query by the filters of
+// the pagination, and an ent order option that orders it by the sort.
+// The order must be given to Order, not Where, so counts leave it out, e.g.
+//
+//	where, order := sortFilter.Ent(pagination)
+//	query.Where(predicate.User(where)).Order(user.OrderOption(order))
+func (sortFilter SortFilter) Ent(pagination *http.Pagination) (func(*entsql.Selector), func(*entsql.Selector)) {
+	where := func(s *entsql.Selector) {
+		for _, filter := range pagination.Filters {
+			column := s.C(sortFilter.column(filter.Field))
+
+			switch filter.Operator {
+			case http.FilterEq:
+				s.Where(entsql.EQ(column, filter.Value))
+
+			case http.FilterNe:
+				s.Where(entsql.NEQ(column, filter.Value))
+
+			case http.FilterGt:
+				s.Where(entsql.GT(column, filter.Value))
+
+			case http.FilterGte:
+				s.Where(entsql.GTE(column, filter.Value))
+
+			case http.FilterLt:
+				s.Where(entsql.LT(column, filter.Value))
+
+			case http.FilterLte:
+				s.Where(entsql.LTE(column, filter.Value))
+
+			case http.FilterIn:
+				s.Where(entsql.In(column, anySlice(filter.Values())...))
+
+			case http.FilterLike:
+				s.Where(entsql.Like(column, filter.Value))
+			}
+		}
+	}
+
+	order := func(s *entsql.Selector) {
+		for _, sort := range pagination.Sort {
+			column := s.C(sortFilter.column(sort.Field))
+
+			if sort.Descending {
+				s.OrderBy(entsql.Desc(column))
+			} else {
+				s.OrderBy(entsql.Asc(column))
+			}
+		}
+	}
+
+	return where, order
+}
+
+// Gorm returns a gorm scope that filters and orders the query by the
+// sort and filters of the pagination, e.g.
+//
+//	db.Scopes(sortFilter.Gorm(pagination)).Find(&rows)
+func (sortFilter SortFilter) Gorm(pagination *http.Pagination) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, filter := range pagination.Filters {
+			column := clause.Column{Name: sortFilter.column(filter.Field)}
+
+			switch filter.Operator {
+			case http.FilterEq:
+				db = db.Where(clause.Eq{Column: column, Value: filter.Value})
+
+			case http.FilterNe:
+				db = db.Where(clause.Neq{Column: column, Value: filter.Value})
+
+			case http.FilterGt:
+				db = db.Where(clause.Gt{Column: column, Value: filter.Value})
+
+			case http.FilterGte:
+				db = db.Where(clause.Gte{Column: column, Value: filter.Value})
+
+			case http.FilterLt:
+				db = db.Where(clause.Lt{Column: column, Value: filter.Value})
+
+			case http.FilterLte:
+				db = db.Where(clause.Lte{Column: column, Value: filter.Value})
+
+			case http.FilterIn:
+				db = db.Where(clause.IN{Column: column, Values: anySlice(filter.Values())})
+
+			case http.FilterLike:
+				db = db.Where(clause.Like{Column: column, Value: filter.Value})
+			}
+		}
+
+		for _, sort := range pagination.Sort {
+			db = db.Order(clause.OrderByColumn{
+				Column: clause.Column{Name: sortFilter.column(sort.Field)},
+				Desc:   sort.Descending,
+			})
+		}
+
+		return db
+	}
+}
+
+func (sortFilter SortFilter) column(field string) string {
+	column, ok := sortFilter.Columns[field]
+	if !ok {
+		return field
+	}
+
+	return column
+}
+
+func anySlice(values []string) []any {
+	args := make([]any, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+
+	return args
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// sqlPoolConfigExtra holds the database/sql pool settings
+	// shared by the sql databases. It is squashed into their extra config.
+	sqlPoolConfigExtra struct {
+		ConnRetry      int `mapstructure:"connRetry"`
+		MaxOpenConns   int `mapstructure:"maxOpenConns"`
+		MaxIdleConns   int `mapstructure:"maxIdleConns"`
+		ConnTimeOut    int `mapstructure:"connTimeOut"`
+		MaxOpenConnTTL int `mapstructure:"maxOpenConnTTL"`
+		MaxIdleConnTTL int `mapstructure:"maxIdleConnTTL"`
+	}
+
+	// dsnConnector is a connector for drivers that only open connections
+	// from a dsn, as sql.Open does.
+	dsnConnector struct {
+		driver driver.Driver
+		dsn    string
+	}
+)
+
+func (connector *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return connector.driver.Open(connector.dsn)
+}
+
+func (connector *dsnConnector) Driver() driver.Driver {
+	return connector.driver
+}
+
+func (extra sqlPoolConfigExtra) configure(pool *sql.DB) {
+	pool.SetConnMaxIdleTime(time.Duration(extra.MaxIdleConnTTL) * time.Second)
+	pool.SetConnMaxLifetime(time.Duration(extra.MaxOpenConnTTL) * time.Second)
+	pool.SetMaxOpenConns(extra.MaxOpenConns)
+	pool.SetMaxIdleConns(extra.MaxIdleConns)
+}
+
+func (extra sqlPoolConfigExtra) connTimeOut() time.Duration {
+	return time.Duration(extra.ConnTimeOut) * time.Second
+}
+
+// ping pings the pool up to connRetry times until it succeeds.
+func (extra sqlPoolConfigExtra) ping(ctx context.Context, pool *sql.DB) error {
+	var (
+		err error
+	)
+
+	for i := 0; i < extra.ConnRetry; i++ {
+
+		switch err = pool.PingContext(ctx); err {
+		case nil:
+			return nil
+
+		case context.Canceled, context.DeadlineExceeded:
+			return errors.Wrap(err, "ping database connection timeout exceeded")
+
+		default:
+		}
+	}
+
+	return errors.Wrap(err, "ping database connection failed")
+}
+
+// discardConn closes the connection without returning it to the pool,
+// e.g. when it may still hold a session lock.
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+
+	conn.Close()
+}
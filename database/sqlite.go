@@ -0,0 +1,173 @@
+//go:build sqlite
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+
+	"github.com/cetnfurkan/core/config"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/mattn/go-sqlite3"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+const (
+	sqliteMemoryMode = "memory"
+)
+
+type (
+	sqliteDatabase[T any] struct {
+		cfg    *sqliteDatabaseConfig
+		client *T
+		pool   *sql.DB
+
+		instrumentation *instrumentation
+	}
+
+	sqliteDatabaseConfig struct {
+		*config.Database
+		Extra sqliteDatabaseConfigExtra
+	}
+
+	sqliteDatabaseConfigExtra struct {
+		sqlPoolConfigExtra `mapstructure:",squash"`
+
+		Mode        string `mapstructure:"mode"`
+		Cache       string `mapstructure:"cache"`
+		JournalMode string `mapstructure:"journalMode"`
+		BusyTimeout int    `mapstructure:"busyTimeout"`
+	}
+)
+
+// NewSQLiteDatabase creates a new sqlite database instance.
+//
+// It takes a config instance whose name is the database file
+// and returns a new database interface instance.
+// Use mode memory in extra config data for an in memory database.
+// The in memory database lives as long as its connection, so the pool
+// keeps a single shared cache connection open and the pool extra config
+// is ignored. A transaction holds that connection until it is done.
+//
+// SQLite requires cgo and is only built with the sqlite build tag,
+// e.g. go build -tags sqlite.
+//
+// It will panic
+// if it fails to unmarhal extra config data,
+// if it fails to open sqlite database or
+// if it fails to run migrations given by WithMigrations.
+func NewSQLiteDatabase[T any](cfg *config.Database, createClient func(*entsql.Driver) *T, opts ...option[T]) Database {
+	database := &sqliteDatabase[T]{
+		cfg: &sqliteDatabaseConfig{
+			Database: cfg,
+		},
+	}
+
+	database.UnmarshalExtra()
+
+	dbOptions := newOptions(opts)
+	database.instrumentation = dbOptions.instrumentation
+
+	err := database.createPool()
+	if err != nil {
+		log.Fatal("failed to create sqlite pool: ", err)
+	}
+
+	if dbOptions.migrations != nil {
+		err = dbOptions.migrations.run(DialectSQLite, database.pool)
+		if err != nil {
+			log.Fatal("failed to run sqlite migrations: ", err)
+		}
+	}
+
+	driver := entsql.OpenDB(dialect.SQLite, database.pool)
+	database.client = createClient(driver)
+
+	dbOptions.applyCallbacks(database.client)
+
+	return database
+}
+
+func (database *sqliteDatabase[T]) Get() any {
+	return database.client
+}
+
+// DB returns the connection pool.
+func (database *sqliteDatabase[T]) DB() *sql.DB {
+	return database.pool
+}
+
+func (database *sqliteDatabase[T]) UnmarshalExtra() {
+	err := mapstructure.Decode(database.cfg.Database.Extra, &database.cfg.Extra)
+	if err != nil {
+		log.Fatal("failed to decode extra config into struct: ", err)
+	}
+}
+
+func (database *sqliteDatabase[T]) createPool() error {
+	ctx, cancel := context.WithTimeout(context.Background(), database.cfg.Extra.connTimeOut())
+	defer cancel()
+
+	connector := &dsnConnector{
+		driver: &sqlite3.SQLiteDriver{},
+		dsn:    database.getDSN(),
+	}
+
+	database.pool = sql.OpenDB(database.instrumentation.wrap(connector, systemSQLite))
+
+	database.cfg.Extra.configure(database.pool)
+
+	// Each connection opens a new in memory database,
+	// so the pool must never close or add one.
+	if database.cfg.Extra.Mode == sqliteMemoryMode {
+		database.pool.SetMaxOpenConns(1)
+		database.pool.SetMaxIdleConns(1)
+		database.pool.SetConnMaxIdleTime(0)
+		database.pool.SetConnMaxLifetime(0)
+	}
+
+	err := database.cfg.Extra.ping(ctx, database.pool)
+	if err != nil {
+		return errors.Wrap(err, "sqlite connection pool ping failed")
+	}
+
+	return nil
+}
+
+func (database *sqliteDatabase[T]) getDSN() string {
+	var (
+		query = make(url.Values)
+	)
+
+	// ent requires foreign keys to be enabled.
+	query.Set("_fk", "1")
+
+	if database.cfg.Extra.Mode != "" {
+		query.Set("mode", database.cfg.Extra.Mode)
+	}
+
+	if database.cfg.Extra.Cache != "" {
+		query.Set("cache", database.cfg.Extra.Cache)
+	}
+
+	if database.cfg.Extra.Mode == sqliteMemoryMode {
+		query.Set("cache", "shared")
+	}
+
+	if database.cfg.Extra.JournalMode != "" {
+		query.Set("_journal_mode", database.cfg.Extra.JournalMode)
+	}
+
+	if database.cfg.Extra.BusyTimeout > 0 {
+		query.Set("_busy_timeout", strconv.Itoa(database.cfg.Extra.BusyTimeout))
+	}
+
+	return fmt.Sprintf("file:%s?%s", database.cfg.Name, query.Encode())
+}
@@ -0,0 +1,359 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	InternalErrorCode = "INTERNAL_ERROR"
+)
+
+type (
+	// AppError is a transport neutral application error.
+	//
+	// It can be returned from the service layer, wrapped and rendered by
+	// both the echo and gRPC servers. Two app errors are equal for
+	// errors.Is when their codes are equal, so declared app errors
+	// can be used as sentinels.
+	AppError struct {
+		Code       string
+		Message    string
+		Details    map[string]any
+		Fields     []FieldError
+		Cause      error
+		Retryable  bool
+		HTTPStatus int
+		GRPCCode   codes.Code
+
+		stack []uintptr
+	}
+
+	// FieldError describes why a field of the request is invalid.
+	FieldError struct {
+		Field   string `json:"field"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+
+	appErrorOption func(*AppError)
+)
+
+var (
+	grpcCodeToHTTPStatus = map[codes.Code]int{
+		codes.OK:                 http.StatusOK,
+		codes.Canceled:           499,
+		codes.Unknown:            http.StatusInternalServerError,
+		codes.InvalidArgument:    http.StatusBadRequest,
+		codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+		codes.NotFound:           http.StatusNotFound,
+		codes.AlreadyExists:      http.StatusConflict,
+		codes.PermissionDenied:   http.StatusForbidden,
+		codes.ResourceExhausted:  http.StatusTooManyRequests,
+		codes.FailedPrecondition: http.StatusPreconditionFailed,
+		codes.Aborted:            http.StatusConflict,
+		codes.OutOfRange:         http.StatusBadRequest,
+		codes.Unimplemented:      http.StatusNotImplemented,
+		codes.Internal:           http.StatusInternalServerError,
+		codes.Unavailable:        http.StatusServiceUnavailable,
+		codes.DataLoss:           http.StatusInternalServerError,
+		codes.Unauthenticated:    http.StatusUnauthorized,
+	}
+
+	httpStatusToGRPCCode = map[int]codes.Code{
+		http.StatusBadRequest:          codes.InvalidArgument,
+		http.StatusUnauthorized:        codes.Unauthenticated,
+		http.StatusForbidden:           codes.PermissionDenied,
+		http.StatusNotFound:            codes.NotFound,
+		http.StatusMethodNotAllowed:    codes.Unimplemented,
+		http.StatusConflict:            codes.AlreadyExists,
+		http.StatusPreconditionFailed:  codes.FailedPrecondition,
+		http.StatusUnprocessableEntity: codes.InvalidArgument,
+		http.StatusTooManyRequests:     codes.ResourceExhausted,
+		499:                            codes.Canceled,
+		http.StatusNotImplemented:      codes.Unimplemented,
+		http.StatusServiceUnavailable:  codes.Unavailable,
+		http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+	}
+)
+
+// WithStatus sets the HTTP status of the error. The gRPC code
+// is derived from it unless WithGrpcCode is given.
+func WithStatus(httpStatus int) appErrorOption {
+	return func(err *AppError) {
+		err.HTTPStatus = httpStatus
+	}
+}
+
+// WithGrpcCode sets the gRPC code of the error. The HTTP status
+// is derived from it unless WithStatus is given.
+func WithGrpcCode(code codes.Code) appErrorOption {
+	return func(err *AppError) {
+		err.GRPCCode = code
+	}
+}
+
+func WithCause(cause error) appErrorOption {
+	return func(err *AppError) {
+		err.Cause = cause
+	}
+}
+
+func WithDetail(key string, value any) appErrorOption {
+	return func(err *AppError) {
+		if err.Details == nil {
+			err.Details = make(map[string]any)
+		}
+
+		err.Details[key] = value
+	}
+}
+
+// WithFieldErrors adds field level errors, e.g. validation errors.
+func WithFieldErrors(fields ...FieldError) appErrorOption {
+	return func(err *AppError) {
+		err.Fields = append(err.Fields, fields...)
+	}
+}
+
+func WithRetryable() appErrorOption {
+	return func(err *AppError) {
+		err.Retryable = true
+	}
+}
+
+// NewAppError creates a new app error instance.
+//
+// It takes an application code, e.g. USER_NOT_FOUND, and a message safe
+// to show to clients. Without WithStatus or WithGrpcCode it is an
+// internal error. The stack trace is captured where it is created.
+func NewAppError(code, message string, opts ...appErrorOption) *AppError {
+	err := &AppError{
+		Code:    code,
+		Message: message,
+		stack:   callers(),
+	}
+
+	for _, opt := range opts {
+		opt(err)
+	}
+
+	switch {
+	case err.HTTPStatus == 0 && err.GRPCCode == codes.OK:
+		err.HTTPStatus = http.StatusInternalServerError
+		err.GRPCCode = codes.Internal
+
+	case err.HTTPStatus == 0:
+		err.HTTPStatus = HTTPStatusFromGrpcCode(err.GRPCCode)
+
+	case err.GRPCCode == codes.OK:
+		err.GRPCCode = GrpcCodeFromHTTPStatus(err.HTTPStatus)
+	}
+
+	return err
+}
+
+// Wrap returns a copy of the error with the given cause,
+// e.g. ErrUserNotFound.Wrap(err). The stack trace of the copy
+// is captured where it is wrapped.
+func (err *AppError) Wrap(cause error, opts ...appErrorOption) *AppError {
+	wrapped := *err
+	wrapped.Cause = cause
+	wrapped.stack = callers()
+
+	if err.Details != nil {
+		wrapped.Details = make(map[string]any, len(err.Details))
+		for key, value := range err.Details {
+			wrapped.Details[key] = value
+		}
+	}
+
+	wrapped.Fields = slices.Clone(err.Fields)
+
+	for _, opt := range opts {
+		opt(&wrapped)
+	}
+
+	return &wrapped
+}
+
+func (err *AppError) Error() string {
+	if err.Cause != nil {
+		return fmt.Sprintf("[%s]: %s: %v", err.Code, err.Message, err.Cause)
+	}
+
+	return fmt.Sprintf("[%s]: %s", err.Code, err.Message)
+}
+
+func (err *AppError) Unwrap() error {
+	return err.Cause
+}
+
+func (err *AppError) Is(target error) bool {
+	appErr, ok := target.(*AppError)
+	return ok && appErr.Code == err.Code
+}
+
+// GRPCStatus converts the error to a gRPC status. It is used by the
+// gRPC server when the error is returned from a handler.
+//
+// Like the HTTP error handler, it hides the message and details of
+// errors with a 5xx status, only their code is sent.
+func (err *AppError) GRPCStatus() *status.Status {
+	if err.HTTPStatus >= http.StatusInternalServerError {
+		return grpcStatus(err.GRPCCode, err.Code, http.StatusText(err.HTTPStatus), nil)
+	}
+
+	return grpcStatus(err.GRPCCode, err.Code, err.Message, err.Details)
+}
+
+// grpcStatus returns a gRPC status with the error code as its error info.
+func grpcStatus(grpcCode codes.Code, code, message string, details map[string]any) *status.Status {
+	st := status.New(grpcCode, message)
+
+	metadata := make(map[string]string, len(details))
+	for key, value := range details {
+		metadata[key] = fmt.Sprint(value)
+	}
+
+	withDetails, detailsErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   code,
+		Metadata: metadata,
+	})
+	if detailsErr != nil {
+		return st
+	}
+
+	return withDetails
+}
+
+// AsAppError returns the app error in the chain of err. Echo errors are
+// converted using their status, other errors are returned as an internal
+// error with err as the cause.
+func AsAppError(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var echoErr *echo.HTTPError
+	if errors.As(err, &echoErr) {
+		message, ok := echoErr.Message.(string)
+		if !ok {
+			message = http.StatusText(echoErr.Code)
+		}
+
+		return NewAppError(
+			statusErrorCode(echoErr.Code),
+			message,
+			WithStatus(echoErr.Code),
+			WithCause(echoErr.Internal),
+		)
+	}
+
+	return NewAppError(InternalErrorCode, http.StatusText(http.StatusInternalServerError), WithCause(err))
+}
+
+// statusErrorCode returns the error code of an HTTP status, e.g. NOT_FOUND.
+func statusErrorCode(httpStatus int) string {
+	text := http.StatusText(httpStatus)
+	if text == "" {
+		return InternalErrorCode
+	}
+
+	return strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// AppErrorResponse writes the error in the same shape as HttpError,
+// with the details of the error if there are any.
+// Errors that are not app errors are written as internal errors.
+func AppErrorResponse(ctx echo.Context, err error, opts ...httpErrorOption) error {
+	appErr := AsAppError(err).Localize(requestLocales(ctx)...)
+
+	httpErr := &httpError{
+		ctx:           ctx,
+		httpErrorInfo: *appErr.HttpErrorInfo(),
+	}
+
+	for _, opt := range opts {
+		opt(httpErr)
+	}
+
+	return DefaultRenderer(ctx, httpErr.ResponseCode, &ErrorEnvelope{
+		Code:    string(httpErr.Code),
+		Message: httpErr.Message,
+		Service: httpErr.ServiceName,
+		Details: appErr.Details,
+		Errors:  appErr.Fields,
+		status:  httpErr.ResponseCode,
+	})
+}
+
+// GrpcStatusContext converts err to a gRPC status like GrpcStatus, with
+// the message of app errors localized for the locales of the context.
+func GrpcStatusContext(ctx context.Context, err error) *status.Status {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.Localize(LocalesFromContext(ctx)...).GRPCStatus()
+	}
+
+	return GrpcStatus(err)
+}
+
+// HttpErrorInfo returns the error info for HttpError.
+func (err *AppError) HttpErrorInfo() *httpErrorInfo {
+	return HttpErrorInfo(HttpErrorCode(err.Code), err.Message, err.HTTPStatus)
+}
+
+// GrpcStatus converts err to a gRPC status. Errors that are neither
+// app errors nor gRPC statuses are converted as internal errors.
+func GrpcStatus(err error) *status.Status {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr.GRPCStatus()
+	}
+
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+
+	return AsAppError(err).GRPCStatus()
+}
+
+// HTTPStatusFromGrpcCode maps a gRPC code to an HTTP status.
+func HTTPStatusFromGrpcCode(code codes.Code) int {
+	httpStatus, ok := grpcCodeToHTTPStatus[code]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+
+	return httpStatus
+}
+
+// GrpcCodeFromHTTPStatus maps an HTTP status to a gRPC code. Statuses
+// below 400 map to Unknown, an error is never sent with the OK code.
+func GrpcCodeFromHTTPStatus(httpStatus int) codes.Code {
+	code, ok := httpStatusToGRPCCode[httpStatus]
+	switch {
+	case ok:
+		return code
+
+	case httpStatus < http.StatusBadRequest:
+		return codes.Unknown
+
+	case httpStatus < http.StatusInternalServerError:
+		return codes.FailedPrecondition
+
+	default:
+		return codes.Internal
+	}
+}
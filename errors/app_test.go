@@ -0,0 +1,154 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewAppErrorMapping(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []appErrorOption
+		wantStatus int
+		wantCode   codes.Code
+	}{
+		{name: "default", wantStatus: http.StatusInternalServerError, wantCode: codes.Internal},
+		{name: "status", opts: []appErrorOption{WithStatus(http.StatusNotFound)}, wantStatus: http.StatusNotFound, wantCode: codes.NotFound},
+		{name: "grpc code", opts: []appErrorOption{WithGrpcCode(codes.Unauthenticated)}, wantStatus: http.StatusUnauthorized, wantCode: codes.Unauthenticated},
+		{name: "both", opts: []appErrorOption{WithStatus(http.StatusConflict), WithGrpcCode(codes.Aborted)}, wantStatus: http.StatusConflict, wantCode: codes.Aborted},
+		{name: "unmapped 4xx", opts: []appErrorOption{WithStatus(http.StatusTeapot)}, wantStatus: http.StatusTeapot, wantCode: codes.FailedPrecondition},
+		{name: "unmapped 5xx", opts: []appErrorOption{WithStatus(http.StatusBadGateway)}, wantStatus: http.StatusBadGateway, wantCode: codes.Internal},
+		{name: "below 400", opts: []appErrorOption{WithStatus(http.StatusFound)}, wantStatus: http.StatusFound, wantCode: codes.Unknown},
+		{name: "unmapped grpc code", opts: []appErrorOption{WithGrpcCode(codes.Code(99))}, wantStatus: http.StatusInternalServerError, wantCode: codes.Code(99)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NewAppError("TEST", "test", test.opts...)
+
+			if err.HTTPStatus != test.wantStatus || err.GRPCCode != test.wantCode {
+				t.Errorf("NewAppError() = %d, %v, want %d, %v", err.HTTPStatus, err.GRPCCode, test.wantStatus, test.wantCode)
+			}
+		})
+	}
+}
+
+func TestAppErrorGRPCStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          *AppError
+		wantCode     codes.Code
+		wantMessage  string
+		wantMetadata map[string]string
+	}{
+		{
+			name:         "client error",
+			err:          NewAppError("USER_NOT_FOUND", "user not found", WithStatus(http.StatusNotFound), WithDetail("id", 42)),
+			wantCode:     codes.NotFound,
+			wantMessage:  "user not found",
+			wantMetadata: map[string]string{"id": "42"},
+		},
+		{
+			name:        "server error",
+			err:         NewAppError("DB_DOWN", "connection refused by 10.0.0.1", WithDetail("host", "10.0.0.1")),
+			wantCode:    codes.Internal,
+			wantMessage: http.StatusText(http.StatusInternalServerError),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			st := test.err.GRPCStatus()
+
+			if st.Code() != test.wantCode || st.Message() != test.wantMessage {
+				t.Errorf("GRPCStatus() = %v, %q, want %v, %q", st.Code(), st.Message(), test.wantCode, test.wantMessage)
+			}
+
+			details := st.Details()
+			if len(details) != 1 {
+				t.Fatalf("GRPCStatus() details = %v, want an error info", details)
+			}
+
+			info, ok := details[0].(*errdetails.ErrorInfo)
+			if !ok || info.Reason != test.err.Code {
+				t.Fatalf("GRPCStatus() details = %v, want an error info with reason %s", details, test.err.Code)
+			}
+
+			if len(info.Metadata) != len(test.wantMetadata) {
+				t.Errorf("GRPCStatus() metadata = %v, want %v", info.Metadata, test.wantMetadata)
+			}
+
+			for key, value := range test.wantMetadata {
+				if info.Metadata[key] != value {
+					t.Errorf("GRPCStatus() metadata = %v, want %v", info.Metadata, test.wantMetadata)
+				}
+			}
+		})
+	}
+}
+
+func TestAsAppError(t *testing.T) {
+	errNotFound := NewAppError("USER_NOT_FOUND", "user not found", WithStatus(http.StatusNotFound))
+	errCause := errors.New("cause")
+
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    string
+		wantStatus  int
+		wantMessage string
+	}{
+		{name: "app error", err: fmt.Errorf("find user: %w", errNotFound.Wrap(errCause)), wantCode: "USER_NOT_FOUND", wantStatus: http.StatusNotFound, wantMessage: "user not found"},
+		{name: "echo error", err: echo.NewHTTPError(http.StatusMethodNotAllowed, "use POST"), wantCode: "METHOD_NOT_ALLOWED", wantStatus: http.StatusMethodNotAllowed, wantMessage: "use POST"},
+		{name: "echo error without message", err: &echo.HTTPError{Code: http.StatusNotFound}, wantCode: "NOT_FOUND", wantStatus: http.StatusNotFound, wantMessage: "Not Found"},
+		{name: "other error", err: errCause, wantCode: InternalErrorCode, wantStatus: http.StatusInternalServerError, wantMessage: "Internal Server Error"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			appErr := AsAppError(test.err)
+
+			if appErr.Code != test.wantCode || appErr.HTTPStatus != test.wantStatus || appErr.Message != test.wantMessage {
+				t.Errorf("AsAppError() = %s, %d, %q, want %s, %d, %q", appErr.Code, appErr.HTTPStatus, appErr.Message, test.wantCode, test.wantStatus, test.wantMessage)
+			}
+		})
+	}
+}
+
+func TestAppErrorIs(t *testing.T) {
+	errNotFound := NewAppError("USER_NOT_FOUND", "user not found", WithStatus(http.StatusNotFound))
+	errCause := errors.New("cause")
+
+	wrapped := fmt.Errorf("find user: %w", errNotFound.Wrap(errCause, WithDetail("id", 1)))
+
+	if !errors.Is(wrapped, errNotFound) || !errors.Is(wrapped, errCause) {
+		t.Errorf("errors.Is() = false for the sentinel or the cause of %v", wrapped)
+	}
+
+	if errors.Is(wrapped, NewAppError("OTHER", "other")) {
+		t.Errorf("errors.Is() = true for an app error with another code")
+	}
+
+	if errNotFound.Details != nil {
+		t.Errorf("Wrap() changed the details of the sentinel to %v", errNotFound.Details)
+	}
+}
+
+func TestGrpcStatus(t *testing.T) {
+	st := GrpcStatus(status.Error(codes.Unavailable, "try later"))
+	if st.Code() != codes.Unavailable || st.Message() != "try later" {
+		t.Errorf("GrpcStatus() = %v, %q, want the status as is", st.Code(), st.Message())
+	}
+
+	st = GrpcStatus(errors.New("boom"))
+	if st.Code() != codes.Internal || st.Message() != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("GrpcStatus() = %v, %q, want a hidden internal error", st.Code(), st.Message())
+	}
+}
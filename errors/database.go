@@ -0,0 +1,16 @@
+package errors
+
+import "errors"
+
+var (
+	ErrConnPoolNotFound        = errors.New("native connection pool not found")
+	ErrSQLDBNotFound           = errors.New("sql database not found")
+	ErrInserterClosed          = errors.New("inserter is closed")
+	ErrTenantNotFound          = errors.New("tenant not found in context")
+	ErrInvalidTenant           = errors.New("invalid tenant")
+	ErrLockAlreadyHeld         = errors.New("lock is already held")
+	ErrHooksNotSupported       = errors.New("ent hooks are not supported by the client")
+	ErrExecQueryNotSupported   = errors.New("ent client is not generated with the sql/execquery feature")
+	ErrDatabaseClosed          = errors.New("database is closed")
+	ErrMigrationLockerRequired = errors.New("migration locker is required")
+)
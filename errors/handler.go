@@ -0,0 +1,147 @@
+package errors
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	errorHandler struct {
+		serviceName    string
+		debug          bool
+		format         ErrorFormat
+		problemOptions []problemOption
+	}
+
+	ErrorHandlerOption func(*errorHandler)
+)
+
+// WithHandlerServiceName sets the service name written to the error responses.
+func WithHandlerServiceName(name string) ErrorHandlerOption {
+	return func(handler *errorHandler) {
+		handler.serviceName = name
+	}
+}
+
+// WithHandlerDebug shows the messages and causes of internal errors
+// in the responses. It should not be enabled in production.
+func WithHandlerDebug(debug bool) ErrorHandlerOption {
+	return func(handler *errorHandler) {
+		handler.debug = debug
+	}
+}
+
+// WithHandlerFormat sets the format of the error responses.
+// Default is ErrorFormatJSON.
+func WithHandlerFormat(format ErrorFormat) ErrorHandlerOption {
+	return func(handler *errorHandler) {
+		handler.format = format
+	}
+}
+
+// WithHandlerProblemOptions sets the options of the problem details
+// written by ErrorFormatProblem and ErrorFormatNegotiate.
+func WithHandlerProblemOptions(opts ...problemOption) ErrorHandlerOption {
+	return func(handler *errorHandler) {
+		handler.problemOptions = append(handler.problemOptions, opts...)
+	}
+}
+
+// HTTPErrorHandler returns an echo error handler that writes every error
+// returned from the handlers and middlewares in the same shape as HttpError,
+// or as problem details, see WithHandlerFormat.
+//
+// App errors are written with their codes and details, echo errors with
+// their statuses and other errors as internal errors. Messages and
+// details of internal errors are hidden unless debug is enabled.
+// Errors are logged as structured fields with their cause chains,
+// stack traces and the request id.
+func HTTPErrorHandler(opts ...ErrorHandlerOption) echo.HTTPErrorHandler {
+	handler := &errorHandler{}
+
+	for _, opt := range opts {
+		opt(handler)
+	}
+
+	return handler.handle
+}
+
+func (handler *errorHandler) handle(err error, ctx echo.Context) {
+	appErr := AsAppError(err)
+
+	handler.log(ctx, appErr)
+
+	if ctx.Response().Committed {
+		return
+	}
+
+	if ctx.Request().Method == http.MethodHead {
+		handler.logWriteError(ctx, ctx.NoContent(appErr.HTTPStatus))
+		return
+	}
+
+	public := handler.public(appErr)
+
+	if handler.format == ErrorFormatProblem || (handler.format == ErrorFormatNegotiate && acceptsProblem(ctx)) {
+		handler.logWriteError(ctx, ProblemResponse(ctx, public, append(handler.problemOptions, WithProblemService(handler.serviceName))...))
+		return
+	}
+
+	handler.logWriteError(ctx, AppErrorResponse(ctx, public, WithServiceName(handler.serviceName)))
+}
+
+// public returns the error as shown to the clients. Messages and details
+// of internal errors are hidden, or the cause is added in debug mode.
+func (handler *errorHandler) public(err *AppError) *AppError {
+	if err.HTTPStatus < http.StatusInternalServerError {
+		return err
+	}
+
+	if !handler.debug {
+		return &AppError{
+			Code:       err.Code,
+			Message:    http.StatusText(err.HTTPStatus),
+			HTTPStatus: err.HTTPStatus,
+			GRPCCode:   err.GRPCCode,
+		}
+	}
+
+	if err.Cause == nil {
+		return err
+	}
+
+	return err.Wrap(err.Cause, WithDetail("cause", err.Cause.Error()))
+}
+
+func (handler *errorHandler) log(ctx echo.Context, err *AppError) {
+	fields := LogFields(err)
+	fields["method"] = ctx.Request().Method
+	fields["path"] = ctx.Path()
+	fields["request_id"] = RequestID(ctx)
+	fields["code"] = err.Code
+	fields["status"] = err.HTTPStatus
+
+	if err.HTTPStatus >= http.StatusInternalServerError {
+		ctx.Logger().Errorj(fields)
+	} else {
+		ctx.Logger().Warnj(fields)
+	}
+}
+
+func (handler *errorHandler) logWriteError(ctx echo.Context, err error) {
+	if err != nil {
+		ctx.Logger().Errorf("failed to write error response: %v", err)
+	}
+}
+
+// RequestID returns the request id set by the request id middleware,
+// or the one sent by the client.
+func RequestID(ctx echo.Context) string {
+	requestID := ctx.Response().Header().Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = ctx.Request().Header.Get(echo.HeaderXRequestID)
+	}
+
+	return requestID
+}
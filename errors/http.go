@@ -17,7 +17,8 @@ type (
 	}
 
 	httpError struct {
-		ctx echo.Context
+		ctx   echo.Context
+		cause error
 		httpErrorInfo
 	}
 
@@ -41,14 +42,26 @@ func HttpError(ctx echo.Context, errorInfo *httpErrorInfo, opts ...httpErrorOpti
 		err.httpErrorInfo = *errorInfo
 	}
 
+	err.Message = DefaultLocalizer.Localize(string(err.Code), nil, err.Message, requestLocales(ctx)...)
+
 	for _, opt := range opts {
 		opt(err)
 	}
 
-	return ctx.JSON(err.ResponseCode, map[string]any{
-		"code":    err.Code,
-		"message": err.Message,
-		"service": err.ServiceName,
+	if err.cause != nil {
+		fields := LogFields(err)
+		fields["request_id"] = RequestID(ctx)
+		fields["code"] = err.Code
+		fields["service"] = err.ServiceName
+
+		ctx.Logger().Errorj(fields)
+	}
+
+	return DefaultRenderer(ctx, err.ResponseCode, &ErrorEnvelope{
+		Code:    string(err.Code),
+		Message: err.Message,
+		Service: err.ServiceName,
+		status:  err.ResponseCode,
 	})
 }
 
@@ -70,6 +83,14 @@ func WithResponseCode(code int) httpErrorOption {
 	}
 }
 
+// WithErrorCause sets the cause of the error. It is logged with its
+// stack trace and is not written to the response.
+func WithErrorCause(cause error) httpErrorOption {
+	return func(err *httpError) {
+		err.cause = cause
+	}
+}
+
 func WithServiceName(name string) httpErrorOption {
 	return func(err *httpError) {
 		err.ServiceName = name
@@ -77,5 +98,13 @@ func WithServiceName(name string) httpErrorOption {
 }
 
 func (err *httpError) Error() string {
+	if err.cause != nil {
+		return fmt.Sprintf("[%s]-[%s]: %s: %v", err.ServiceName, err.Code, err.Message, err.cause)
+	}
+
 	return fmt.Sprintf("[%s]-[%s]: %s", err.ServiceName, err.Code, err.Message)
 }
+
+func (err *httpError) Unwrap() error {
+	return err.cause
+}
@@ -0,0 +1,217 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	DefaultLocale = "en"
+
+	// AcceptLanguageMetadataKey is the gRPC metadata key the locales are read from.
+	AcceptLanguageMetadataKey = "accept-language"
+)
+
+var (
+	// DefaultLocalizer localizes the messages written by HttpError
+	// and the app error adapters. It has no messages by default.
+	DefaultLocalizer = NewLocalizer(DefaultLocale)
+)
+
+type (
+	// Localizer holds the message templates of the error codes by locale.
+	//
+	// Templates refer to the params with braces, e.g.
+	// "Kullanıcı {id} bulunamadı". The details of app errors are
+	// used as params.
+	Localizer struct {
+		mu            sync.RWMutex
+		defaultLocale string
+		bundles       map[string]map[string]string
+	}
+
+	localeContextKey struct{}
+)
+
+// NewLocalizer creates a new localizer instance. The default locale
+// is the last locale tried for a message.
+func NewLocalizer(defaultLocale string) *Localizer {
+	return &Localizer{
+		defaultLocale: normalizeLocale(defaultLocale),
+		bundles:       make(map[string]map[string]string),
+	}
+}
+
+// Add adds the message templates of the locale, keyed by error code.
+func (localizer *Localizer) Add(locale string, messages map[string]string) {
+	locale = normalizeLocale(locale)
+
+	localizer.mu.Lock()
+	defer localizer.mu.Unlock()
+
+	bundle, ok := localizer.bundles[locale]
+	if !ok {
+		bundle = make(map[string]string, len(messages))
+		localizer.bundles[locale] = bundle
+	}
+
+	for code, message := range messages {
+		bundle[code] = message
+	}
+}
+
+// Load adds the locale bundles of the files matching the pattern, e.g.
+// "locales/*.json". Each file is a JSON object of message templates
+// keyed by error code and is named after its locale, e.g. tr.json.
+// It works with embed.FS.
+func (localizer *Localizer) Load(fsys fs.FS, pattern string) error {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+
+		messages := make(map[string]string)
+
+		err = json.Unmarshal(content, &messages)
+		if err != nil {
+			return fmt.Errorf("failed to parse locale bundle %s: %w", file, err)
+		}
+
+		localizer.Add(strings.TrimSuffix(path.Base(file), path.Ext(file)), messages)
+	}
+
+	return nil
+}
+
+// Localize returns the message of the code in the first locale that has
+// it. Each locale falls back to its base language, e.g. tr-TR to tr, and
+// then to the default locale. The fallback is returned when no locale
+// has the message.
+func (localizer *Localizer) Localize(code string, params map[string]any, fallback string, locales ...string) string {
+	localizer.mu.RLock()
+	defer localizer.mu.RUnlock()
+
+	for _, locale := range localizer.chain(locales) {
+		message, ok := localizer.bundles[locale][code]
+		if ok {
+			return formatMessage(message, params)
+		}
+	}
+
+	return fallback
+}
+
+// chain returns the locales tried for a message in order.
+func (localizer *Localizer) chain(locales []string) []string {
+	chain := make([]string, 0, 2*len(locales)+1)
+
+	for _, locale := range locales {
+		locale = normalizeLocale(locale)
+		chain = append(chain, locale)
+
+		base, _, found := strings.Cut(locale, "-")
+		if found {
+			chain = append(chain, base)
+		}
+	}
+
+	return append(chain, localizer.defaultLocale)
+}
+
+// Localize returns a copy of the error with the message localized
+// by DefaultLocalizer, see Localizer.Localize.
+func (err *AppError) Localize(locales ...string) *AppError {
+	message := DefaultLocalizer.Localize(err.Code, err.Details, err.Message, locales...)
+	if message == err.Message {
+		return err
+	}
+
+	localized := *err
+	localized.Message = message
+
+	return &localized
+}
+
+// WithLocale returns a context that carries the preferred locales of the client.
+func WithLocale(ctx context.Context, locales ...string) context.Context {
+	return context.WithValue(ctx, localeContextKey{}, locales)
+}
+
+// LocalesFromContext returns the locales carried by the context,
+// or read from the accept-language metadata of a gRPC request.
+func LocalesFromContext(ctx context.Context) []string {
+	locales, ok := ctx.Value(localeContextKey{}).([]string)
+	if ok {
+		return locales
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	return ParseAcceptLanguage(strings.Join(md.Get(AcceptLanguageMetadataKey), ","))
+}
+
+// requestLocales returns the locales of an echo request, read from the
+// request context or the Accept-Language header.
+func requestLocales(ctx echo.Context) []string {
+	locales, ok := ctx.Request().Context().Value(localeContextKey{}).([]string)
+	if ok {
+		return locales
+	}
+
+	return ParseAcceptLanguage(ctx.Request().Header.Get("Accept-Language"))
+}
+
+// ParseAcceptLanguage returns the locales of an Accept-Language header
+// ordered by preference.
+func ParseAcceptLanguage(header string) []string {
+	if header == "" {
+		return nil
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return nil
+	}
+
+	locales := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		locales = append(locales, tag.String())
+	}
+
+	return locales
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+func formatMessage(message string, params map[string]any) string {
+	if len(params) == 0 {
+		return message
+	}
+
+	replacements := make([]string, 0, 2*len(params))
+	for key, value := range params {
+		replacements = append(replacements, "{"+key+"}", fmt.Sprint(value))
+	}
+
+	return strings.NewReplacer(replacements...).Replace(message)
+}
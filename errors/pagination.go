@@ -0,0 +1,18 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrInvalidCursor        = errors.New("invalid cursor")
+	ErrCursorSecretNotFound = errors.New("cursor secret not found")
+
+	ErrInvalidPagination = Register(ErrorDefinition{
+		Code:        "INVALID_PAGINATION",
+		HTTPStatus:  http.StatusBadRequest,
+		Message:     "invalid pagination",
+		Description: "The page, size, sort, filter, after or before query params are invalid, see the errors member.",
+	})
+)
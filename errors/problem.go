@@ -0,0 +1,157 @@
+package errors
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	MIMEApplicationProblemJSON = "application/problem+json"
+)
+
+const (
+	// ErrorFormatJSON writes the errors in the shape of HttpError.
+	ErrorFormatJSON ErrorFormat = iota
+	// ErrorFormatProblem writes the errors as RFC 7807 problem details.
+	ErrorFormatProblem
+	// ErrorFormatNegotiate writes the errors as problem details when the
+	// client accepts application/problem+json, otherwise as ErrorFormatJSON.
+	ErrorFormatNegotiate
+)
+
+type (
+	ErrorFormat int
+
+	// Problem is an RFC 7807 problem details object.
+	//
+	// Code and Service are the members of HttpError, Errors holds the
+	// field errors and Extensions any other extension members.
+	Problem struct {
+		Type       string
+		Title      string
+		Status     int
+		Detail     string
+		Instance   string
+		Code       string
+		Service    string
+		Errors     []FieldError
+		Extensions map[string]any
+	}
+
+	problemOption func(*Problem)
+)
+
+// WithProblemTypeBaseURL sets the type of the problem to the base url
+// followed by the lower case error code, e.g. https://errors.example.com/user_not_found.
+// By default the type is about:blank.
+func WithProblemTypeBaseURL(baseURL string) problemOption {
+	return func(problem *Problem) {
+		if problem.Code == "" {
+			return
+		}
+
+		problem.Type = strings.TrimSuffix(baseURL, "/") + "/" + strings.ToLower(problem.Code)
+	}
+}
+
+func WithProblemInstance(instance string) problemOption {
+	return func(problem *Problem) {
+		problem.Instance = instance
+	}
+}
+
+func WithProblemService(name string) problemOption {
+	return func(problem *Problem) {
+		problem.Service = name
+	}
+}
+
+// NewProblem creates the problem details of an error.
+// Errors that are not app errors are described as internal errors.
+func NewProblem(err error, opts ...problemOption) *Problem {
+	appErr := AsAppError(err)
+
+	problem := &Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(appErr.HTTPStatus),
+		Status:     appErr.HTTPStatus,
+		Detail:     appErr.Message,
+		Code:       appErr.Code,
+		Errors:     appErr.Fields,
+		Extensions: appErr.Details,
+	}
+
+	for _, opt := range opts {
+		opt(problem)
+	}
+
+	return problem
+}
+
+// ProblemResponse writes the error as an application/problem+json response.
+// The detail is localized for the locales of the request.
+// The instance of the problem is the request path unless it is given.
+func ProblemResponse(ctx echo.Context, err error, opts ...problemOption) error {
+	problem := NewProblem(
+		AsAppError(err).Localize(requestLocales(ctx)...),
+		append([]problemOption{WithProblemInstance(ctx.Request().URL.Path)}, opts...)...,
+	)
+	return problem.write(ctx)
+}
+
+func (problem *Problem) write(ctx echo.Context) error {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Blob(problem.Status, MIMEApplicationProblemJSON, body)
+}
+
+func (problem *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(problem.Extensions)+8)
+
+	for key, value := range problem.Extensions {
+		members[key] = value
+	}
+
+	members["type"] = problem.Type
+	members["title"] = problem.Title
+	members["status"] = problem.Status
+
+	if problem.Detail != "" {
+		members["detail"] = problem.Detail
+	}
+
+	if problem.Instance != "" {
+		members["instance"] = problem.Instance
+	}
+
+	if problem.Code != "" {
+		members["code"] = problem.Code
+	}
+
+	members["service"] = problem.Service
+
+	if len(problem.Errors) > 0 {
+		members["errors"] = problem.Errors
+	}
+
+	return json.Marshal(members)
+}
+
+// acceptsProblem reports whether the client accepts problem details.
+func acceptsProblem(ctx echo.Context) bool {
+	for _, accepted := range strings.Split(ctx.Request().Header.Get(echo.HeaderAccept), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == MIMEApplicationProblemJSON {
+			return true
+		}
+	}
+
+	return false
+}
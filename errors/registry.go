@@ -0,0 +1,211 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+var (
+	ErrDuplicateErrorCode = errors.New("duplicate error code")
+	ErrEmptyErrorCode     = errors.New("empty error code")
+
+	// DefaultRegistry is the registry used by Register.
+	DefaultRegistry = NewRegistry()
+)
+
+type (
+	Severity string
+
+	// ErrorDefinition describes an error code of the catalog.
+	//
+	// The gRPC code is derived from the HTTP status when it is not set,
+	// and the other way around, see NewAppError.
+	ErrorDefinition struct {
+		Code        string
+		HTTPStatus  int
+		GRPCCode    codes.Code
+		Message     string
+		Severity    Severity
+		Description string
+	}
+
+	// Registry is a catalog of the error codes of a service.
+	Registry struct {
+		mu          sync.RWMutex
+		definitions map[string]*ErrorDefinition
+	}
+)
+
+func NewRegistry() *Registry {
+	return &Registry{
+		definitions: make(map[string]*ErrorDefinition),
+	}
+}
+
+// Register adds the definition to the default registry and returns
+// the app error of the definition, e.g.
+//
+//	var ErrUserNotFound = errors.Register(errors.ErrorDefinition{
+//		Code:       "USER_NOT_FOUND",
+//		HTTPStatus: http.StatusNotFound,
+//		Message:    "user not found",
+//	})
+//
+// It will panic if the code is empty or already registered.
+func Register(definition ErrorDefinition) *AppError {
+	err, registerErr := DefaultRegistry.Register(definition)
+	if registerErr != nil {
+		log.Fatal("failed to register error code: ", registerErr)
+	}
+
+	return err
+}
+
+// Register adds the definition to the registry and returns
+// the app error of the definition.
+func (registry *Registry) Register(definition ErrorDefinition) (*AppError, error) {
+	if definition.Code == "" {
+		return nil, ErrEmptyErrorCode
+	}
+
+	err := NewAppError(
+		definition.Code,
+		definition.Message,
+		WithStatus(definition.HTTPStatus),
+		WithGrpcCode(definition.GRPCCode),
+	)
+
+	// Registered errors are sentinels, their stacks are captured by Wrap.
+	err.stack = nil
+
+	definition.HTTPStatus = err.HTTPStatus
+	definition.GRPCCode = err.GRPCCode
+
+	if definition.Severity == "" {
+		definition.Severity = SeverityError
+		if definition.HTTPStatus < http.StatusInternalServerError {
+			definition.Severity = SeverityWarning
+		}
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.definitions[definition.Code]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateErrorCode, definition.Code)
+	}
+
+	registry.definitions[definition.Code] = &definition
+
+	return err, nil
+}
+
+// Lookup returns the definition of the code.
+func (registry *Registry) Lookup(code string) (ErrorDefinition, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	definition, ok := registry.definitions[code]
+	if !ok {
+		return ErrorDefinition{}, false
+	}
+
+	return *definition, true
+}
+
+// Definitions returns the definitions sorted by code.
+func (registry *Registry) Definitions() []ErrorDefinition {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	definitions := make([]ErrorDefinition, 0, len(registry.definitions))
+	for _, definition := range registry.definitions {
+		definitions = append(definitions, *definition)
+	}
+
+	slices.SortFunc(definitions, func(a, b ErrorDefinition) int {
+		return strings.Compare(a.Code, b.Code)
+	})
+
+	return definitions
+}
+
+// JSON exports the catalog as a JSON array.
+func (registry *Registry) JSON() ([]byte, error) {
+	definitions := registry.Definitions()
+
+	catalog := make([]map[string]any, 0, len(definitions))
+	for _, definition := range definitions {
+		catalog = append(catalog, map[string]any{
+			"code":        definition.Code,
+			"http_status": definition.HTTPStatus,
+			"grpc_code":   definition.GRPCCode.String(),
+			"message":     definition.Message,
+			"severity":    definition.Severity,
+			"description": definition.Description,
+		})
+	}
+
+	return json.MarshalIndent(catalog, "", "  ")
+}
+
+// Markdown exports the catalog as a Markdown table.
+func (registry *Registry) Markdown() []byte {
+	buf := &bytes.Buffer{}
+
+	buf.WriteString("| Code | HTTP status | gRPC code | Severity | Message | Description |\n")
+	buf.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+
+	for _, definition := range registry.Definitions() {
+		fmt.Fprintf(
+			buf,
+			"| `%s` | %d | %s | %s | %s | %s |\n",
+			definition.Code,
+			definition.HTTPStatus,
+			definition.GRPCCode,
+			definition.Severity,
+			markdownCell(definition.Message),
+			markdownCell(definition.Description),
+		)
+	}
+
+	return buf.Bytes()
+}
+
+// Handler serves the catalog as JSON, or as Markdown when the format
+// query param is markdown or the client accepts text/markdown.
+func (registry *Registry) Handler() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		if ctx.QueryParam("format") == "markdown" || strings.Contains(ctx.Request().Header.Get(echo.HeaderAccept), "text/markdown") {
+			return ctx.Blob(http.StatusOK, "text/markdown; charset=UTF-8", registry.Markdown())
+		}
+
+		catalog, err := registry.JSON()
+		if err != nil {
+			return err
+		}
+
+		return ctx.JSONBlob(http.StatusOK, catalog)
+	}
+}
+
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
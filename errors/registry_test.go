@@ -0,0 +1,79 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestRegistryRegister(t *testing.T) {
+	registry := NewRegistry()
+
+	err, registerErr := registry.Register(ErrorDefinition{
+		Code:       "USER_NOT_FOUND",
+		HTTPStatus: http.StatusNotFound,
+		Message:    "user not found",
+	})
+	if registerErr != nil {
+		t.Fatalf("Register() error = %v", registerErr)
+	}
+
+	if err.Code != "USER_NOT_FOUND" || err.GRPCCode != codes.NotFound {
+		t.Errorf("Register() = %s, %v, want USER_NOT_FOUND, %v", err.Code, err.GRPCCode, codes.NotFound)
+	}
+
+	definition, ok := registry.Lookup("USER_NOT_FOUND")
+	if !ok {
+		t.Fatal("Lookup() ok = false for a registered code")
+	}
+
+	if definition.GRPCCode != codes.NotFound || definition.Severity != SeverityWarning {
+		t.Errorf("Lookup() = %v, %s, want the derived grpc code and severity %s", definition.GRPCCode, definition.Severity, SeverityWarning)
+	}
+}
+
+func TestRegistryRegisterRejectsDuplicates(t *testing.T) {
+	registry := NewRegistry()
+
+	_, err := registry.Register(ErrorDefinition{Code: "USER_NOT_FOUND", HTTPStatus: http.StatusNotFound})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	_, err = registry.Register(ErrorDefinition{Code: "USER_NOT_FOUND", HTTPStatus: http.StatusGone})
+	if !errors.Is(err, ErrDuplicateErrorCode) {
+		t.Errorf("Register() of a duplicate error = %v, want %v", err, ErrDuplicateErrorCode)
+	}
+
+	definition, _ := registry.Lookup("USER_NOT_FOUND")
+	if definition.HTTPStatus != http.StatusNotFound {
+		t.Errorf("Lookup() status = %d, want the first definition with %d", definition.HTTPStatus, http.StatusNotFound)
+	}
+
+	_, err = registry.Register(ErrorDefinition{HTTPStatus: http.StatusNotFound})
+	if !errors.Is(err, ErrEmptyErrorCode) {
+		t.Errorf("Register() of an empty code error = %v, want %v", err, ErrEmptyErrorCode)
+	}
+}
+
+func TestRegistryDefinitionsSorted(t *testing.T) {
+	registry := NewRegistry()
+
+	for _, code := range []string{"B", "C", "A"} {
+		_, err := registry.Register(ErrorDefinition{Code: code})
+		if err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	definitions := registry.Definitions()
+	if len(definitions) != 3 || definitions[0].Code != "A" || definitions[2].Code != "C" {
+		t.Errorf("Definitions() = %v, want A, B and C", definitions)
+	}
+
+	if definitions[0].Severity != SeverityError {
+		t.Errorf("Definitions() severity = %s, want %s for internal errors", definitions[0].Severity, SeverityError)
+	}
+}
@@ -0,0 +1,42 @@
+package errors
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrUnsupportedRenderValue = errors.New("value is not supported by the renderer")
+
+	// DefaultRenderer writes the error envelopes. It writes JSON unless
+	// it is replaced, the http package replaces it with http.Render
+	// so errors are content negotiated like the other responses.
+	DefaultRenderer RenderFunc = func(ctx echo.Context, status int, body any) error {
+		return ctx.JSON(status, body)
+	}
+)
+
+type (
+	RenderFunc func(ctx echo.Context, status int, body any) error
+
+	// ErrorEnvelope is the body written by HttpError and AppErrorResponse.
+	ErrorEnvelope struct {
+		Code    string         `json:"code"`
+		Message string         `json:"message"`
+		Service string         `json:"service"`
+		Details map[string]any `json:"details,omitempty"`
+		Errors  []FieldError   `json:"errors,omitempty"`
+
+		status int
+	}
+)
+
+// Proto returns the envelope as a google.rpc.Status, the same message
+// the gRPC server sends for the error.
+// The envelope is already made public by the error handler,
+// so its message and details are kept as they are.
+func (envelope *ErrorEnvelope) Proto() proto.Message {
+	return grpcStatus(GrpcCodeFromHTTPStatus(envelope.status), envelope.Code, envelope.Message, envelope.Details).Proto()
+}
@@ -0,0 +1,128 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	pkgErrors "github.com/pkg/errors"
+)
+
+const (
+	maxStackDepth = 32
+)
+
+type (
+	// stackTracer is implemented by the errors of pkg/errors and AppError.
+	stackTracer interface {
+		StackTrace() pkgErrors.StackTrace
+	}
+)
+
+// callers returns the stack of the caller of the function calling it.
+func callers() []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(3, pcs)
+
+	return pcs[:n]
+}
+
+// StackTrace returns the stack where the error was created or wrapped.
+// It implements the stack tracer interface of pkg/errors.
+func (err *AppError) StackTrace() pkgErrors.StackTrace {
+	trace := make(pkgErrors.StackTrace, len(err.stack))
+	for i, pc := range err.stack {
+		trace[i] = pkgErrors.Frame(pc)
+	}
+
+	return trace
+}
+
+// Format prints the cause chain and the stack trace with %+v.
+func (err *AppError) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		_, _ = io.WriteString(s, err.Error())
+
+		for _, cause := range ErrorChain(err)[1:] {
+			_, _ = fmt.Fprintf(s, "\ncaused by: %s", cause)
+		}
+
+		for _, frame := range StackTrace(err) {
+			_, _ = fmt.Fprintf(s, "\n\t%s", frame)
+		}
+
+	case verb == 'q':
+		_, _ = fmt.Fprintf(s, "%q", err.Error())
+
+	default:
+		_, _ = io.WriteString(s, err.Error())
+	}
+}
+
+// ErrorChain returns the messages of err and its causes, outermost first.
+// Wrappers that only add a stack, as in pkg/errors, are skipped.
+func ErrorChain(err error) []string {
+	var chain []string
+
+	for err != nil {
+		message := err.Error()
+		if len(chain) == 0 || chain[len(chain)-1] != message {
+			chain = append(chain, message)
+		}
+
+		err = errors.Unwrap(err)
+	}
+
+	return chain
+}
+
+// StackTrace returns the frames of the innermost stack trace in the chain
+// of err, formatted as "function file:line". Stacks are captured by
+// NewAppError, AppError.Wrap and the functions of pkg/errors.
+func StackTrace(err error) []string {
+	var trace pkgErrors.StackTrace
+
+	for err != nil {
+		tracer, ok := err.(stackTracer)
+		if ok && len(tracer.StackTrace()) > 0 {
+			trace = tracer.StackTrace()
+		}
+
+		err = errors.Unwrap(err)
+	}
+
+	frames := make([]string, 0, len(trace))
+	for _, frame := range trace {
+		pc := uintptr(frame) - 1
+
+		fn := runtime.FuncForPC(pc)
+		if fn == nil {
+			continue
+		}
+
+		file, line := fn.FileLine(pc)
+		frames = append(frames, fmt.Sprintf("%s %s:%d", fn.Name(), file, line))
+	}
+
+	return frames
+}
+
+// LogFields returns the error, its cause chain and stack trace as
+// structured log fields. They are meant for server logs only.
+func LogFields(err error) map[string]any {
+	fields := map[string]any{
+		"error": err.Error(),
+	}
+
+	if chain := ErrorChain(err); len(chain) > 1 {
+		fields["causes"] = chain[1:]
+	}
+
+	if stack := StackTrace(err); len(stack) > 0 {
+		fields["stack"] = stack
+	}
+
+	return fields
+}
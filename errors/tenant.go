@@ -0,0 +1,12 @@
+package errors
+
+import "net/http"
+
+var (
+	ErrTenantForbidden = Register(ErrorDefinition{
+		Code:        "TENANT_FORBIDDEN",
+		HTTPStatus:  http.StatusForbidden,
+		Message:     "tenant is not allowed",
+		Description: "The tenant of the request is rejected by the tenant validator, e.g. the caller is not a member of it.",
+	})
+)
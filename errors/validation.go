@@ -0,0 +1,19 @@
+package errors
+
+import "net/http"
+
+var (
+	ErrInvalidRequest = Register(ErrorDefinition{
+		Code:        "INVALID_REQUEST",
+		HTTPStatus:  http.StatusBadRequest,
+		Message:     "invalid request",
+		Description: "The request could not be bound, e.g. malformed body or wrong param types.",
+	})
+
+	ErrValidationFailed = Register(ErrorDefinition{
+		Code:        "VALIDATION_FAILED",
+		HTTPStatus:  http.StatusBadRequest,
+		Message:     "validation failed",
+		Description: "One or more fields of the request are invalid, see the errors member.",
+	})
+)
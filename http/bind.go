@@ -0,0 +1,205 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	_ echo.Validator = (*Validator)(nil)
+
+	defaultValidator = NewValidator()
+)
+
+type (
+	// Validator validates structs by their validate tags, see
+	// github.com/go-playground/validator. Failures are returned as
+	// errors.ErrValidationFailed with the field errors.
+	Validator struct {
+		validate *validator.Validate
+	}
+
+	ValidatorOption func(*Validator) error
+)
+
+// WithValidation registers a custom rule for the tag, e.g. "slug".
+func WithValidation(tag string, fn validator.Func) ValidatorOption {
+	return func(v *Validator) error {
+		return v.validate.RegisterValidation(tag, fn)
+	}
+}
+
+// WithStructValidation registers a rule validating the given struct
+// types as a whole, e.g. to compare two fields.
+func WithStructValidation(fn validator.StructLevelFunc, types ...any) ValidatorOption {
+	return func(v *Validator) error {
+		v.validate.RegisterStructValidation(fn, types...)
+		return nil
+	}
+}
+
+// NewValidator creates a new validator instance.
+//
+// Fields are named by their json, query, param or header tags
+// in the field errors.
+//
+// It will panic if a custom rule fails to register.
+func NewValidator(opts ...ValidatorOption) *Validator {
+	v := &Validator{
+		validate: validator.New(validator.WithRequiredStructEnabled()),
+	}
+
+	v.validate.RegisterTagNameFunc(fieldName)
+
+	for _, opt := range opts {
+		if err := opt(v); err != nil {
+			log.Fatal("failed to register validation: ", err)
+		}
+	}
+
+	return v
+}
+
+// Validate validates a struct. Other values are not validated.
+func (v *Validator) Validate(i any) error {
+	if reflect.Indirect(reflect.ValueOf(i)).Kind() != reflect.Struct {
+		return nil
+	}
+
+	err := v.validate.Struct(i)
+	if err == nil {
+		return nil
+	}
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return coreErrors.ErrInvalidRequest.Wrap(err)
+	}
+
+	fields := make([]coreErrors.FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		fields = append(fields, fieldError(fieldErr))
+	}
+
+	return coreErrors.ErrValidationFailed.Wrap(err, coreErrors.WithFieldErrors(fields...))
+}
+
+// Bind binds the path params, query params, headers and body of the
+// request into a new T, in this order, and validates it with the
+// validator of echo. Without a validator it uses a default Validator.
+// Bodies are decoded by the renderer of their content type, see
+// RegisterRenderer.
+//
+// Errors are app errors, so they can be returned from the handler as is:
+// errors.ErrInvalidRequest when the request can't be bound and
+// errors.ErrValidationFailed with the field errors when it is invalid.
+func Bind[T any](c echo.Context) (T, error) {
+	var req T
+
+	binder := &echo.DefaultBinder{}
+
+	err := binder.BindPathParams(c, &req)
+	if err == nil {
+		err = binder.BindQueryParams(c, &req)
+	}
+
+	if err == nil {
+		err = binder.BindHeaders(c, &req)
+	}
+
+	if err == nil {
+		err = bindBody(c, binder, &req)
+	}
+
+	if err != nil {
+		return req, coreErrors.ErrInvalidRequest.Wrap(err)
+	}
+
+	validate := c.Echo().Validator
+	if validate == nil {
+		validate = defaultValidator
+	}
+
+	err = validate.Validate(&req)
+	if err != nil {
+		return req, err
+	}
+
+	return req, nil
+}
+
+// fieldName returns the name of a field as the client sends it.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "query", "param", "header", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		switch name {
+		case "-":
+			return ""
+
+		case "":
+			continue
+
+		default:
+			return name
+		}
+	}
+
+	return field.Name
+}
+
+// fieldError converts a validation error, the field is named
+// by its path without the root struct, e.g. address.city.
+func fieldError(err validator.FieldError) coreErrors.FieldError {
+	field := err.Namespace()
+	if _, path, found := strings.Cut(field, "."); found {
+		field = path
+	}
+
+	return coreErrors.FieldError{
+		Field:   field,
+		Code:    err.Tag(),
+		Message: fieldErrorMessage(field, err),
+	}
+}
+
+func fieldErrorMessage(field string, err validator.FieldError) string {
+	switch err.Tag() {
+	case "required", "required_if", "required_unless", "required_with", "required_without":
+		return fmt.Sprintf("%s is required", field)
+
+	case "email":
+		return fmt.Sprintf("%s must be a valid email", field)
+
+	case "min", "gte":
+		return fmt.Sprintf("%s must be at least %s", field, err.Param())
+
+	case "max", "lte":
+		return fmt.Sprintf("%s must be at most %s", field, err.Param())
+
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, err.Param())
+
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, err.Param())
+
+	case "len":
+		return fmt.Sprintf("%s must be %s long", field, err.Param())
+
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, err.Param())
+
+	default:
+		if err.Param() != "" {
+			return fmt.Sprintf("%s failed the %s=%s rule", field, err.Tag(), err.Param())
+		}
+
+		return fmt.Sprintf("%s failed the %s rule", field, err.Tag())
+	}
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+)
+
+// Cursor holds the sort keys of the row a keyset page starts from.
+type Cursor struct {
+	values []json.RawMessage
+}
+
+// EncodeCursor encodes the sort keys into an opaque token signed with the secret.
+func EncodeCursor(secret []byte, values ...any) (string, error) {
+	payload, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	signature := base64.RawURLEncoding.EncodeToString(signCursor(secret, payload))
+
+	return encoded + "." + signature, nil
+}
+
+// DecodeCursor verifies the token with the secret and returns its cursor.
+// It returns ErrInvalidCursor if the token is malformed or tampered with.
+func DecodeCursor(secret []byte, token string) (*Cursor, error) {
+	encoded, signature, ok := bytes.Cut([]byte(token), []byte("."))
+	if !ok {
+		return nil, coreErrors.ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, coreErrors.ErrInvalidCursor
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(string(signature))
+	if err != nil || !hmac.Equal(mac, signCursor(secret, payload)) {
+		return nil, coreErrors.ErrInvalidCursor
+	}
+
+	cursor := &Cursor{}
+
+	err = json.Unmarshal(payload, &cursor.values)
+	if err != nil {
+		return nil, coreErrors.ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// Scan copies the sort keys into dest in the order they were encoded,
+// e.g. cursor.Scan(&createdAt, &id).
+func (cursor *Cursor) Scan(dest ...any) error {
+	if len(dest) != len(cursor.values) {
+		return coreErrors.ErrInvalidCursor
+	}
+
+	for i, value := range cursor.values {
+		err := json.Unmarshal(value, dest[i])
+		if err != nil {
+			return coreErrors.ErrInvalidCursor
+		}
+	}
+
+	return nil
+}
+
+func signCursor(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
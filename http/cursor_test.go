@@ -0,0 +1,114 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+)
+
+var (
+	testCursorSecret = []byte("secret")
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	token, err := EncodeCursor(testCursorSecret, createdAt, 42)
+	if err != nil {
+		t.Fatalf("EncodeCursor() error = %v", err)
+	}
+
+	cursor, err := DecodeCursor(testCursorSecret, token)
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+
+	var (
+		gotCreatedAt time.Time
+		gotID        int
+	)
+
+	err = cursor.Scan(&gotCreatedAt, &gotID)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if !gotCreatedAt.Equal(createdAt) || gotID != 42 {
+		t.Errorf("Scan() = %v, %d, want %v, %d", gotCreatedAt, gotID, createdAt, 42)
+	}
+}
+
+func TestDecodeCursorRejectsTamperedTokens(t *testing.T) {
+	token, err := EncodeCursor(testCursorSecret, "a", 1)
+	if err != nil {
+		t.Fatalf("EncodeCursor() error = %v", err)
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`["a",2]`))
+
+	tests := []struct {
+		name   string
+		secret []byte
+		token  string
+	}{
+		{name: "payload", secret: testCursorSecret, token: forged + "." + signature},
+		{name: "signature", secret: testCursorSecret, token: payload + "." + signature[:len(signature)-2] + "AA"},
+		{name: "secret", secret: []byte("other"), token: token},
+		{name: "no signature", secret: testCursorSecret, token: payload},
+		{name: "empty signature", secret: testCursorSecret, token: payload + "."},
+		{name: "bad encoding", secret: testCursorSecret, token: "!!!." + signature},
+		{name: "empty", secret: testCursorSecret, token: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodeCursor(test.secret, test.token)
+			if !errors.Is(err, coreErrors.ErrInvalidCursor) {
+				t.Errorf("DecodeCursor() error = %v, want %v", err, coreErrors.ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsSignedNonArrays(t *testing.T) {
+	payload := []byte(`{"id":1}`)
+	token := base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(signCursor(testCursorSecret, payload))
+
+	_, err := DecodeCursor(testCursorSecret, token)
+	if !errors.Is(err, coreErrors.ErrInvalidCursor) {
+		t.Errorf("DecodeCursor() error = %v, want %v", err, coreErrors.ErrInvalidCursor)
+	}
+}
+
+func TestCursorScanRejectsMismatchedValues(t *testing.T) {
+	token, err := EncodeCursor(testCursorSecret, "a", 1)
+	if err != nil {
+		t.Fatalf("EncodeCursor() error = %v", err)
+	}
+
+	cursor, err := DecodeCursor(testCursorSecret, token)
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+
+	var (
+		id   int
+		name string
+	)
+
+	err = cursor.Scan(&id)
+	if !errors.Is(err, coreErrors.ErrInvalidCursor) {
+		t.Errorf("Scan() with fewer values error = %v, want %v", err, coreErrors.ErrInvalidCursor)
+	}
+
+	err = cursor.Scan(&id, &name)
+	if !errors.Is(err, coreErrors.ErrInvalidCursor) {
+		t.Errorf("Scan() with wrong types error = %v, want %v", err, coreErrors.ErrInvalidCursor)
+	}
+}
@@ -1,17 +1,80 @@
 package http
 
 import (
+	"fmt"
 	"strconv"
 
+	coreErrors "github.com/cetnfurkan/core/errors"
+
 	"github.com/labstack/echo/v4"
 )
 
-type Pagination struct {
-	Data         any     `json:"data,omitempty"`
-	Search       *string `json:"search,omitempty"`
-	TotalElement *int    `json:"total_element,omitempty"`
-	Page         *int    `json:"page,omitempty"`
-	Size         *int    `json:"size,omitempty"`
+type (
+	Pagination struct {
+		Data         any     `json:"data,omitempty"`
+		Search       *string `json:"search,omitempty"`
+		TotalElement *int    `json:"total_element,omitempty"`
+		Page         *int    `json:"page,omitempty"`
+		Size         *int    `json:"size,omitempty"`
+		NextCursor   *string `json:"next_cursor,omitempty"`
+		PrevCursor   *string `json:"prev_cursor,omitempty"`
+
+		// Sort and Filters are parsed from the sort and filter query
+		// params of the fields allowed by Paginate.
+		Sort    []SortField `json:"-"`
+		Filters []Filter    `json:"-"`
+
+		// After and Before are the cursors given by the after and
+		// before query params, at most one of them is set.
+		After  *Cursor `json:"-"`
+		Before *Cursor `json:"-"`
+
+		cursorSecret []byte
+	}
+
+	paginateOptions struct {
+		cursorSecret []byte
+		defaultSize  int
+		maxSize      int
+		sortFields   []string
+		filterFields []string
+	}
+
+	paginateOption func(*paginateOptions)
+)
+
+// WithCursorSecret enables keyset pagination with cursors signed by the secret.
+func WithCursorSecret(secret []byte) paginateOption {
+	return func(opts *paginateOptions) {
+		opts.cursorSecret = secret
+	}
+}
+
+// WithPageSize sets the size used when the size query param is missing,
+// and the largest size allowed. A max size of 0 allows any size.
+// Default is 20 and 100.
+func WithPageSize(defaultSize, maxSize int) paginateOption {
+	return func(opts *paginateOptions) {
+		opts.defaultSize = defaultSize
+		opts.maxSize = maxSize
+	}
+}
+
+// WithSortFields allows sorting by the fields with the sort query param,
+// e.g. sort=name,-created_at. Without it the sort query param is ignored.
+func WithSortFields(fields ...string) paginateOption {
+	return func(opts *paginateOptions) {
+		opts.sortFields = append(opts.sortFields, fields...)
+	}
+}
+
+// WithFilterFields allows filtering by the fields with the filter query
+// params, e.g. filter[status]=active or created_at[gte]=2024-01-01.
+// Without it the filter query params are ignored.
+func WithFilterFields(fields ...string) paginateOption {
+	return func(opts *paginateOptions) {
+		opts.filterFields = append(opts.filterFields, fields...)
+	}
 }
 
 func NewPagination(search string, page, size int) *Pagination {
@@ -22,14 +85,75 @@ func NewPagination(search string, page, size int) *Pagination {
 	}
 }
 
-func Paginate() echo.MiddlewareFunc {
+// Paginate parses the pagination query params into the context.
+//
+// The page defaults to 1 and the size to the default size. Pages and
+// sizes that are not positive integers, sizes above the max size and
+// sort or filter params of fields that are not allowed are rejected
+// with errors.ErrInvalidPagination, which is written as 400 by the
+// error handler.
+//
+// Sizes above 100 are rejected unless WithPageSize sets another max
+// size. Once WithFilterFields is given, every query param shaped like a filter,
+// e.g. filter[name] or name[eq], must be of an allowed field, so
+// handlers can't use such params for anything else.
+//
+// With WithCursorSecret, the after and before query params are verified
+// and decoded into the cursors of the pagination. Invalid cursors are
+// rejected with errors.ErrInvalidPagination as well.
+func Paginate(opts ...paginateOption) echo.MiddlewareFunc {
+	paginateOpts := &paginateOptions{
+		defaultSize: 20,
+		maxSize:     100,
+	}
+
+	for _, opt := range opts {
+		opt(paginateOpts)
+	}
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) (err error) {
 			search := c.QueryParam("search")
-			p, _ := strconv.Atoi(c.QueryParam("page"))
-			s, _ := strconv.Atoi(c.QueryParam("size"))
+
+			p, err := parsePositive(c.QueryParam("page"), "page", 1)
+			if err != nil {
+				return err
+			}
+
+			s, err := parsePositive(c.QueryParam("size"), "size", paginateOpts.defaultSize)
+			if err != nil {
+				return err
+			}
+
+			if paginateOpts.maxSize > 0 && s > paginateOpts.maxSize {
+				return paginationError("size", "max", fmt.Sprintf("size must be at most %d", paginateOpts.maxSize))
+			}
 
 			pg := NewPagination(search, p, s)
+
+			if paginateOpts.sortFields != nil {
+				pg.Sort, err = parseSort(c.QueryParam("sort"), paginateOpts.sortFields)
+				if err != nil {
+					return err
+				}
+			}
+
+			if paginateOpts.filterFields != nil {
+				pg.Filters, err = parseFilters(c.QueryParams(), paginateOpts.filterFields)
+				if err != nil {
+					return err
+				}
+			}
+
+			if paginateOpts.cursorSecret != nil {
+				pg.cursorSecret = paginateOpts.cursorSecret
+
+				err = pg.decodeCursors(c.QueryParam("after"), c.QueryParam("before"))
+				if err != nil {
+					return err
+				}
+			}
+
 			c.Set("page", pg)
 
 			return next(c)
@@ -37,6 +161,21 @@ func Paginate() echo.MiddlewareFunc {
 	}
 }
 
+// parsePositive parses a positive integer query param, or returns
+// the default value when it is missing.
+func parsePositive(value, param string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, paginationError(param, "invalid", fmt.Sprintf("%s must be a positive integer", param))
+	}
+
+	return n, nil
+}
+
 func GetPagination(c echo.Context) *Pagination {
 	p := c.Get("page")
 
@@ -49,9 +188,67 @@ func GetPagination(c echo.Context) *Pagination {
 }
 
 func (p *Pagination) Offset() int {
-	if p.Page == nil || p.Size == nil {
+	if p.Page == nil || p.Size == nil || *p.Page < 1 {
 		return 0
 	}
 
 	return (*(p.Page) - 1) * *(p.Size)
 }
+
+// SetNextCursor sets next_cursor from the sort keys of the last row.
+// It returns an error if Paginate has no cursor secret.
+func (p *Pagination) SetNextCursor(values ...any) error {
+	return p.setCursor(&p.NextCursor, values)
+}
+
+// SetPrevCursor sets prev_cursor from the sort keys of the first row.
+// It returns an error if Paginate has no cursor secret.
+func (p *Pagination) SetPrevCursor(values ...any) error {
+	return p.setCursor(&p.PrevCursor, values)
+}
+
+func (p *Pagination) setCursor(field **string, values []any) error {
+	if p.cursorSecret == nil {
+		return coreErrors.ErrCursorSecretNotFound
+	}
+
+	token, err := EncodeCursor(p.cursorSecret, values...)
+	if err != nil {
+		return err
+	}
+
+	*field = &token
+	return nil
+}
+
+func (p *Pagination) decodeCursors(after, before string) (err error) {
+	if after != "" && before != "" {
+		return paginationError("before", "excluded_with", "before can't be used with after")
+	}
+
+	if after != "" {
+		p.After, err = DecodeCursor(p.cursorSecret, after)
+		if err != nil {
+			return cursorError("after", err)
+		}
+	}
+
+	if before != "" {
+		p.Before, err = DecodeCursor(p.cursorSecret, before)
+		if err != nil {
+			return cursorError("before", err)
+		}
+	}
+
+	return nil
+}
+
+// Cursor returns the cursor of the after or before query param, or nil
+// on the first page.
+func (p *Pagination) Cursor() *Cursor {
+	if p.Before != nil {
+		return p.Before
+	}
+
+	return p.After
+}
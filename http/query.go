@@ -0,0 +1,144 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+)
+
+const (
+	FilterEq   FilterOperator = "eq"
+	FilterNe   FilterOperator = "ne"
+	FilterGt   FilterOperator = "gt"
+	FilterGte  FilterOperator = "gte"
+	FilterLt   FilterOperator = "lt"
+	FilterLte  FilterOperator = "lte"
+	FilterIn   FilterOperator = "in"
+	FilterLike FilterOperator = "like"
+)
+
+var (
+	filterOperators = []FilterOperator{FilterEq, FilterNe, FilterGt, FilterGte, FilterLt, FilterLte, FilterIn, FilterLike}
+
+	// filterParamRegexp matches filter[field], filter[field][op] and field[op].
+	filterParamRegexp = regexp.MustCompile(`^(?:filter\[(\w+)\](?:\[(\w+)\])?|(\w+)\[(\w+)\])$`)
+)
+
+type (
+	FilterOperator string
+
+	// SortField is a field of the sort query param.
+	SortField struct {
+		Field      string
+		Descending bool
+	}
+
+	// Filter is a field filter of the query params, e.g. filter[status]=active
+	// or created_at[gte]=2024-01-01.
+	Filter struct {
+		Field    string
+		Operator FilterOperator
+		Value    string
+	}
+)
+
+// Values returns the comma separated values of an in filter.
+func (filter Filter) Values() []string {
+	return strings.Split(filter.Value, ",")
+}
+
+// parseSort parses a sort query param, e.g. "name,-created_at".
+// Fields prefixed with - are sorted in descending order.
+func parseSort(param string, allowed []string) ([]SortField, error) {
+	if param == "" {
+		return nil, nil
+	}
+
+	var fields []SortField
+
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+
+		sortField := SortField{
+			Field:      strings.TrimPrefix(strings.TrimPrefix(field, "-"), "+"),
+			Descending: strings.HasPrefix(field, "-"),
+		}
+
+		if !slices.Contains(allowed, sortField.Field) {
+			return nil, paginationError("sort", "invalid", fmt.Sprintf("sorting by %q is not allowed", sortField.Field))
+		}
+
+		fields = append(fields, sortField)
+	}
+
+	return fields, nil
+}
+
+// parseFilters parses the filter query params of the allowed fields.
+// Filters are ordered by their params.
+func parseFilters(params url.Values, allowed []string) ([]Filter, error) {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	var filters []Filter
+
+	for _, key := range keys {
+		match := filterParamRegexp.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		field, operator := match[1], FilterOperator(match[2])
+		if field == "" {
+			field, operator = match[3], FilterOperator(match[4])
+		}
+
+		if operator == "" {
+			operator = FilterEq
+		}
+
+		if !slices.Contains(allowed, field) {
+			return nil, paginationError(key, "invalid", fmt.Sprintf("filtering by %q is not allowed", field))
+		}
+
+		if !slices.Contains(filterOperators, operator) {
+			return nil, paginationError(key, "invalid", fmt.Sprintf("filter operator %q is not supported", operator))
+		}
+
+		for _, value := range params[key] {
+			filters = append(filters, Filter{
+				Field:    field,
+				Operator: operator,
+				Value:    value,
+			})
+		}
+	}
+
+	return filters, nil
+}
+
+// cursorError returns the error of an invalid cursor param with the
+// verification error as its cause.
+func cursorError(param string, err error) error {
+	return coreErrors.ErrInvalidPagination.Wrap(err, coreErrors.WithFieldErrors(coreErrors.FieldError{
+		Field:   param,
+		Code:    "invalid",
+		Message: fmt.Sprintf("%s must be a valid cursor", param),
+	}))
+}
+
+func paginationError(param, code, message string) error {
+	return coreErrors.ErrInvalidPagination.Wrap(nil, coreErrors.WithFieldErrors(coreErrors.FieldError{
+		Field:   param,
+		Code:    code,
+		Message: message,
+	}))
+}
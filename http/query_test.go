@@ -0,0 +1,247 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestParseSort(t *testing.T) {
+	allowed := []string{"name", "created_at"}
+
+	tests := []struct {
+		name    string
+		param   string
+		want    []SortField
+		wantErr bool
+	}{
+		{name: "empty", param: ""},
+		{
+			name:  "allowed",
+			param: "name, -created_at,+name",
+			want: []SortField{
+				{Field: "name"},
+				{Field: "created_at", Descending: true},
+				{Field: "name"},
+			},
+		},
+		{name: "not allowed", param: "name,password", wantErr: true},
+		{name: "prefix only", param: "-", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseSort(test.param, allowed)
+			if test.wantErr {
+				if !errors.Is(err, coreErrors.ErrInvalidPagination) {
+					t.Fatalf("parseSort() error = %v, want %v", err, coreErrors.ErrInvalidPagination)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseSort() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseSort() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	allowed := []string{"status", "created_at"}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    []Filter
+		wantErr bool
+	}{
+		{name: "no filters", query: "page=1&q=x"},
+		{
+			name:  "allowed",
+			query: "filter[status]=active&filter[created_at][gte]=2024-01-01&created_at[lt]=2025-01-01",
+			want: []Filter{
+				{Field: "created_at", Operator: FilterLt, Value: "2025-01-01"},
+				{Field: "created_at", Operator: FilterGte, Value: "2024-01-01"},
+				{Field: "status", Operator: FilterEq, Value: "active"},
+			},
+		},
+		{
+			name:  "repeated",
+			query: "status[in]=a,b&status[in]=c",
+			want: []Filter{
+				{Field: "status", Operator: FilterIn, Value: "a,b"},
+				{Field: "status", Operator: FilterIn, Value: "c"},
+			},
+		},
+		{name: "field not allowed", query: "filter[password]=x", wantErr: true},
+		{name: "bracketed field not allowed", query: "password[eq]=x", wantErr: true},
+		{name: "operator not supported", query: "filter[status][regex]=.*", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := url.ParseQuery(test.query)
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+
+			got, err := parseFilters(params, allowed)
+			if test.wantErr {
+				if !errors.Is(err, coreErrors.ErrInvalidPagination) {
+					t.Fatalf("parseFilters() error = %v, want %v", err, coreErrors.ErrInvalidPagination)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseFilters() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseFilters() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterValues(t *testing.T) {
+	got := Filter{Operator: FilterIn, Value: "a,b,c"}.Values()
+	want := []string{"a", "b", "c"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	cursor, err := EncodeCursor(testCursorSecret, 1)
+	if err != nil {
+		t.Fatalf("EncodeCursor() error = %v", err)
+	}
+
+	middleware := Paginate(
+		WithPageSize(10, 50),
+		WithSortFields("name"),
+		WithFilterFields("status"),
+		WithCursorSecret(testCursorSecret),
+	)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantField string
+	}{
+		{name: "valid", query: "page=2&size=50&sort=-name&filter[status]=active&after=" + cursor},
+		{name: "page", query: "page=0", wantField: "page"},
+		{name: "size", query: "size=abc", wantField: "size"},
+		{name: "max size", query: "size=51", wantField: "size"},
+		{name: "sort", query: "sort=password", wantField: "sort"},
+		{name: "filter", query: "filter[password]=x", wantField: "filter[password]"},
+		{name: "tampered cursor", query: "after=" + cursor + "x", wantField: "after"},
+		{name: "both cursors", query: "after=" + cursor + "&before=" + cursor, wantField: "before"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?"+test.query, nil)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			var pagination *Pagination
+
+			err := middleware(func(c echo.Context) error {
+				pagination = GetPagination(c)
+				return nil
+			})(c)
+
+			if test.wantField == "" {
+				if err != nil {
+					t.Fatalf("Paginate() error = %v", err)
+				}
+
+				if *pagination.Page != 2 || *pagination.Size != 50 || len(pagination.Sort) != 1 ||
+					len(pagination.Filters) != 1 || pagination.After == nil {
+					t.Errorf("Paginate() = %+v, want page 2, size 50, a sort, a filter and a cursor", pagination)
+				}
+
+				return
+			}
+
+			var appErr *coreErrors.AppError
+			if !errors.As(err, &appErr) || !errors.Is(err, coreErrors.ErrInvalidPagination) {
+				t.Fatalf("Paginate() error = %v, want %v", err, coreErrors.ErrInvalidPagination)
+			}
+
+			if appErr.HTTPStatus != http.StatusBadRequest {
+				t.Errorf("Paginate() status = %d, want %d", appErr.HTTPStatus, http.StatusBadRequest)
+			}
+
+			if len(appErr.Fields) != 1 || appErr.Fields[0].Field != test.wantField {
+				t.Errorf("Paginate() fields = %v, want a %s field error", appErr.Fields, test.wantField)
+			}
+		})
+	}
+}
+
+func TestPaginateDefaults(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?filter[status]=x&sort=name", nil)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	err := Paginate()(func(c echo.Context) error { return nil })(c)
+	if err != nil {
+		t.Fatalf("Paginate() error = %v", err)
+	}
+
+	pagination := GetPagination(c)
+
+	if *pagination.Page != 1 || *pagination.Size != 20 {
+		t.Errorf("Paginate() page, size = %d, %d, want 1, 20", *pagination.Page, *pagination.Size)
+	}
+
+	if pagination.Sort != nil || pagination.Filters != nil {
+		t.Errorf("Paginate() sort, filters = %v, %v, want none without allowed fields", pagination.Sort, pagination.Filters)
+	}
+}
+
+func TestPaginateDefaultMaxSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		wantErr bool
+	}{
+		{size: "100"},
+		{size: "101", wantErr: true},
+		{size: "1000", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.size, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?size="+test.size, nil)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			err := Paginate()(func(c echo.Context) error { return nil })(c)
+			if test.wantErr {
+				if !errors.Is(err, coreErrors.ErrInvalidPagination) {
+					t.Errorf("Paginate() error = %v, want %v", err, coreErrors.ErrInvalidPagination)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Paginate() error = %v", err)
+			}
+		})
+	}
+}
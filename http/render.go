@@ -0,0 +1,399 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	MIMEApplicationMsgpack  = "application/msgpack"
+	MIMEApplicationProtobuf = "application/x-protobuf"
+)
+
+var (
+	_ Renderer = JSONRenderer{}
+	_ Renderer = XMLRenderer{}
+	_ Renderer = MsgpackRenderer{}
+	_ Renderer = ProtobufRenderer{}
+
+	renderers = &rendererRegistry{
+		renderers: make(map[string]Renderer),
+	}
+)
+
+type (
+	// Renderer encodes the responses and decodes the request bodies
+	// of a content type.
+	//
+	// Marshal returns errors.ErrUnsupportedRenderValue for values it
+	// can't encode, so the next acceptable content type is tried.
+	Renderer interface {
+		ContentType() string
+		Marshal(v any) ([]byte, error)
+		Unmarshal(data []byte, v any) error
+	}
+
+	// Protoer is implemented by the response envelopes to be rendered
+	// as protobuf, see ProtobufRenderer.
+	Protoer interface {
+		Proto() proto.Message
+	}
+
+	JSONRenderer     struct{}
+	XMLRenderer      struct{}
+	MsgpackRenderer  struct{}
+	ProtobufRenderer struct{}
+
+	rendererRegistry struct {
+		mu        sync.RWMutex
+		renderers map[string]Renderer
+	}
+
+	acceptedType struct {
+		mediaType string
+		quality   float64
+	}
+)
+
+func init() {
+	RegisterRenderer(JSONRenderer{}, echo.MIMEApplicationJSON)
+	RegisterRenderer(XMLRenderer{}, echo.MIMEApplicationXML, echo.MIMETextXML)
+	RegisterRenderer(MsgpackRenderer{}, MIMEApplicationMsgpack, "application/x-msgpack", "application/vnd.msgpack")
+	RegisterRenderer(ProtobufRenderer{}, MIMEApplicationProtobuf, "application/protobuf", "application/vnd.google.protobuf")
+
+	// Error envelopes follow the same negotiation as the other responses.
+	coreErrors.DefaultRenderer = Render
+}
+
+// RegisterRenderer registers the renderer for its content type and the
+// given aliases, replacing the renderers registered before for them.
+func RegisterRenderer(renderer Renderer, mediaTypes ...string) {
+	renderers.mu.Lock()
+	defer renderers.mu.Unlock()
+
+	for _, mediaType := range append([]string{renderer.ContentType()}, mediaTypes...) {
+		renderers.renderers[strings.ToLower(mediaType)] = renderer
+	}
+}
+
+func lookupRenderer(mediaType string) (Renderer, bool) {
+	renderers.mu.RLock()
+	defer renderers.mu.RUnlock()
+
+	renderer, ok := renderers.renderers[strings.ToLower(mediaType)]
+	return renderer, ok
+}
+
+// Render writes v with the renderer of the most preferred content type
+// of the Accept header that can encode it. It writes JSON when the client
+// accepts any type or no registered type can encode the value.
+func Render(c echo.Context, status int, v any) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+	for _, accepted := range parseAccept(c.Request().Header.Get(echo.HeaderAccept)) {
+		renderer, ok := lookupRenderer(accepted)
+		if !ok {
+			continue
+		}
+
+		data, err := renderer.Marshal(v)
+		if errors.Is(err, coreErrors.ErrUnsupportedRenderValue) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return c.Blob(status, renderer.ContentType(), data)
+	}
+
+	data, err := JSONRenderer{}.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.Blob(status, JSONRenderer{}.ContentType(), data)
+}
+
+// bindBody decodes the body with the renderer of its content type,
+// other bodies, e.g. forms, are bound by echo.
+func bindBody(c echo.Context, binder *echo.DefaultBinder, v any) error {
+	req := c.Request()
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+
+	renderer, ok := lookupRenderer(mediaType)
+	if !ok {
+		return binder.BindBody(c, v)
+	}
+
+	if req.ContentLength == 0 {
+		return nil
+	}
+
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	return renderer.Unmarshal(data, v)
+}
+
+// parseAccept returns the media types of an Accept header ordered by
+// quality. Types with a quality of 0 are left out.
+func parseAccept(header string) []string {
+	var accepted []acceptedType
+
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		if quality > 0 {
+			accepted = append(accepted, acceptedType{mediaType: mediaType, quality: quality})
+		}
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].quality > accepted[j].quality
+	})
+
+	mediaTypes := make([]string, len(accepted))
+	for i, accepted := range accepted {
+		mediaTypes[i] = accepted.mediaType
+	}
+
+	return mediaTypes
+}
+
+func (JSONRenderer) ContentType() string {
+	return echo.MIMEApplicationJSONCharsetUTF8
+}
+
+func (JSONRenderer) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONRenderer) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (XMLRenderer) ContentType() string {
+	return echo.MIMEApplicationXMLCharsetUTF8
+}
+
+// Marshal encodes v in a response element. Values are converted
+// as they are encoded to JSON, so maps and the json tags are supported:
+// objects become elements by key and arrays repeated item elements.
+// Keys that are not valid element names, e.g. "user id" or "1st",
+// become entry elements with a key attribute.
+func (XMLRenderer) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+
+	err = decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBufferString(xml.Header)
+	encoder := xml.NewEncoder(buf)
+
+	err = encodeXML(encoder, xmlElement("response"), value)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encoder.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (XMLRenderer) Unmarshal(data []byte, v any) error {
+	return xml.Unmarshal(data, v)
+}
+
+func (MsgpackRenderer) ContentType() string {
+	return MIMEApplicationMsgpack
+}
+
+// Marshal encodes v with its json tags, so it has the shape of the JSON responses.
+func (MsgpackRenderer) Marshal(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	encoder := msgpack.NewEncoder(buf)
+	encoder.SetCustomStructTag("json")
+
+	err := encoder.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (MsgpackRenderer) Unmarshal(data []byte, v any) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.SetCustomStructTag("json")
+
+	return decoder.Decode(v)
+}
+
+func (ProtobufRenderer) ContentType() string {
+	return MIMEApplicationProtobuf
+}
+
+// Marshal encodes proto messages and the messages of Protoer values,
+// e.g. the data of a response or the google.rpc.Status of an error.
+// Other values are not supported.
+func (ProtobufRenderer) Marshal(v any) ([]byte, error) {
+	message, ok := v.(proto.Message)
+	if !ok {
+		if protoer, ok := v.(Protoer); ok {
+			message = protoer.Proto()
+		}
+	}
+
+	if message == nil {
+		return nil, coreErrors.ErrUnsupportedRenderValue
+	}
+
+	return proto.Marshal(message)
+}
+
+func (ProtobufRenderer) Unmarshal(data []byte, v any) error {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return coreErrors.ErrUnsupportedRenderValue
+	}
+
+	return proto.Unmarshal(data, message)
+}
+
+// Proto returns the data of the pagination if it is a proto message,
+// so responses with proto data can be rendered as protobuf.
+func (p *Pagination) Proto() proto.Message {
+	message, _ := p.Data.(proto.Message)
+	return message
+}
+
+func encodeXML(encoder *xml.Encoder, start xml.StartElement, value any) error {
+	switch value := value.(type) {
+	case nil:
+		return nil
+
+	case map[string]any:
+		err := encoder.EncodeToken(start)
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			err = encodeXML(encoder, xmlElement(key), value[key])
+			if err != nil {
+				return err
+			}
+		}
+
+		return encoder.EncodeToken(start.End())
+
+	case []any:
+		err := encoder.EncodeToken(start)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range value {
+			err = encodeXML(encoder, xmlElement("item"), item)
+			if err != nil {
+				return err
+			}
+		}
+
+		return encoder.EncodeToken(start.End())
+
+	default:
+		return encoder.EncodeElement(value, start)
+	}
+}
+
+// xmlElement returns the element of a map key, keys that are not valid
+// names become entry elements with a key attribute.
+func xmlElement(key string) xml.StartElement {
+	if isXMLName(key) {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+
+	return xml.StartElement{
+		Name: xml.Name{Local: "entry"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+	}
+}
+
+// isXMLName reports whether the name can be used as an element name.
+// Names with colons and the reserved xml prefix are not allowed.
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r), r == '_':
+
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []string
+	}{
+		{name: "empty", header: "", want: []string{}},
+		{name: "single", header: "application/xml", want: []string{"application/xml"}},
+		{name: "quality", header: "application/xml;q=0.5, application/msgpack, */*;q=0.1", want: []string{"application/msgpack", "application/xml", "*/*"}},
+		{name: "stable", header: "application/xml, application/json", want: []string{"application/xml", "application/json"}},
+		{name: "refused", header: "application/xml;q=0, application/json", want: []string{"application/json"}},
+		{name: "invalid", header: "application/xml;q=abc, /, application/json", want: []string{"application/json"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseAccept(test.header)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseAccept() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderNegotiation(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		value  any
+		want   string
+	}{
+		{name: "no accept", value: map[string]any{"a": 1}, want: echo.MIMEApplicationJSONCharsetUTF8},
+		{name: "any", accept: "*/*", value: map[string]any{"a": 1}, want: echo.MIMEApplicationJSONCharsetUTF8},
+		{name: "xml", accept: "application/xml", value: map[string]any{"a": 1}, want: echo.MIMEApplicationXMLCharsetUTF8},
+		{name: "xml alias", accept: "text/xml", value: map[string]any{"a": 1}, want: echo.MIMEApplicationXMLCharsetUTF8},
+		{name: "msgpack", accept: "application/vnd.msgpack", value: map[string]any{"a": 1}, want: MIMEApplicationMsgpack},
+		{name: "preferred", accept: "application/xml;q=0.5, application/msgpack", value: map[string]any{"a": 1}, want: MIMEApplicationMsgpack},
+		{name: "unknown", accept: "text/html", value: map[string]any{"a": 1}, want: echo.MIMEApplicationJSONCharsetUTF8},
+		{name: "protobuf", accept: MIMEApplicationProtobuf, value: wrapperspb.String("a"), want: MIMEApplicationProtobuf},
+		{name: "protobuf data", accept: MIMEApplicationProtobuf, value: &Pagination{Data: wrapperspb.String("a")}, want: MIMEApplicationProtobuf},
+		{name: "protobuf unsupported", accept: MIMEApplicationProtobuf + ", application/xml;q=0.5", value: map[string]any{"a": 1}, want: echo.MIMEApplicationXMLCharsetUTF8},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.accept != "" {
+				req.Header.Set(echo.HeaderAccept, test.accept)
+			}
+
+			rec := httptest.NewRecorder()
+
+			err := Render(echo.New().NewContext(req, rec), http.StatusOK, test.value)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			if got := rec.Header().Get(echo.HeaderContentType); got != test.want {
+				t.Errorf("Render() content type = %q, want %q", got, test.want)
+			}
+
+			if got := rec.Header().Get(echo.HeaderVary); got != echo.HeaderAccept {
+				t.Errorf("Render() vary = %q, want %q", got, echo.HeaderAccept)
+			}
+		})
+	}
+}
+
+func TestXMLRendererSanitizesKeys(t *testing.T) {
+	value := map[string]any{
+		"name":    "<n>",
+		"user id": 1,
+		"1st":     "a",
+		"a:b":     2,
+		"xmlns":   "x",
+		"list":    []any{1, "b"},
+		"nested":  map[string]any{"ok": true},
+		"empty":   nil,
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<response>` +
+		`<entry key="1st">a</entry>` +
+		`<entry key="a:b">2</entry>` +
+		`<list><item>1</item><item>b</item></list>` +
+		`<name>&lt;n&gt;</name>` +
+		`<nested><ok>true</ok></nested>` +
+		`<entry key="user id">1</entry>` +
+		`<entry key="xmlns">x</entry>` +
+		`</response>`
+
+	data, err := XMLRenderer{}.Marshal(value)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestIsXMLName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "user", want: true},
+		{name: "_id", want: true},
+		{name: "created-at.utc", want: true},
+		{name: "ünicode", want: true},
+		{name: ""},
+		{name: "1st"},
+		{name: "-a"},
+		{name: "a b"},
+		{name: "ns:a"},
+		{name: "XmlThing"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isXMLName(test.name); got != test.want {
+				t.Errorf("isXMLName() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
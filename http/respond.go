@@ -0,0 +1,230 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderDeprecation = "Deprecation"
+	HeaderSunset      = "Sunset"
+)
+
+type (
+	// ResponseBuilder builds a response in the shape of Response,
+	// with status, headers, metadata, links and warnings.
+	ResponseBuilder struct {
+		ctx        echo.Context
+		status     int
+		code       string
+		data       any
+		pagination *Pagination
+		meta       map[string]any
+		links      map[string]string
+		warnings   []string
+	}
+
+	envelope struct {
+		*Pagination
+		Code       string            `json:"code"`
+		TotalPages *int              `json:"total_pages,omitempty"`
+		HasNext    *bool             `json:"has_next,omitempty"`
+		HasPrev    *bool             `json:"has_prev,omitempty"`
+		Meta       map[string]any    `json:"meta,omitempty"`
+		Links      map[string]string `json:"links,omitempty"`
+		Warnings   []string          `json:"warnings,omitempty"`
+		RequestID  string            `json:"request_id,omitempty"`
+	}
+)
+
+// Respond returns a response builder, e.g.
+//
+//	return http.Respond(c).Status(http.StatusCreated).Data(user).Send()
+//
+// The status is 200 unless it is given.
+func Respond(ctx echo.Context) *ResponseBuilder {
+	return &ResponseBuilder{
+		ctx:    ctx,
+		status: http.StatusOK,
+	}
+}
+
+func (builder *ResponseBuilder) Status(status int) *ResponseBuilder {
+	builder.status = status
+	return builder
+}
+
+func (builder *ResponseBuilder) Code(code string) *ResponseBuilder {
+	builder.code = code
+	return builder
+}
+
+func (builder *ResponseBuilder) Data(data any) *ResponseBuilder {
+	builder.data = data
+	return builder
+}
+
+// Pagination adds the pagination fields, the page counts and
+// the self, first, prev, next and last links.
+func (builder *ResponseBuilder) Pagination(pagination *Pagination) *ResponseBuilder {
+	builder.pagination = pagination
+	return builder
+}
+
+func (builder *ResponseBuilder) Meta(key string, value any) *ResponseBuilder {
+	if builder.meta == nil {
+		builder.meta = make(map[string]any)
+	}
+
+	builder.meta[key] = value
+	return builder
+}
+
+// Links adds links by relation, e.g. {"author": "/users/1"}.
+func (builder *ResponseBuilder) Links(links map[string]string) *ResponseBuilder {
+	if builder.links == nil {
+		builder.links = make(map[string]string, len(links))
+	}
+
+	for rel, href := range links {
+		builder.links[rel] = href
+	}
+
+	return builder
+}
+
+func (builder *ResponseBuilder) Header(key, value string) *ResponseBuilder {
+	builder.ctx.Response().Header().Set(key, value)
+	return builder
+}
+
+func (builder *ResponseBuilder) Warning(warning string) *ResponseBuilder {
+	builder.warnings = append(builder.warnings, warning)
+	return builder
+}
+
+// Deprecated marks the endpoint as deprecated with the Deprecation and
+// Sunset headers and a warning. The sunset is omitted when it is zero.
+func (builder *ResponseBuilder) Deprecated(sunset time.Time) *ResponseBuilder {
+	builder.Header(HeaderDeprecation, "true")
+
+	if sunset.IsZero() {
+		return builder.Warning("this endpoint is deprecated")
+	}
+
+	builder.Header(HeaderSunset, sunset.UTC().Format(http.TimeFormat))
+
+	return builder.Warning(fmt.Sprintf("this endpoint is deprecated and will be removed after %s", sunset.UTC().Format(time.DateOnly)))
+}
+
+// Send writes the response in the content type negotiated by Render.
+// Responses with 204 or 304 have no body.
+func (builder *ResponseBuilder) Send() error {
+	if builder.status == http.StatusNoContent || builder.status == http.StatusNotModified {
+		return builder.ctx.NoContent(builder.status)
+	}
+
+	resp := envelope{
+		Pagination: &Pagination{},
+		Code:       builder.code,
+		Meta:       builder.meta,
+		Links:      builder.links,
+		Warnings:   builder.warnings,
+		RequestID:  coreErrors.RequestID(builder.ctx),
+	}
+
+	if builder.pagination != nil {
+		pagination := *builder.pagination
+		resp.Pagination = &pagination
+
+		builder.paginate(&resp)
+	}
+
+	// Keep the data of the pagination unless the builder has its own.
+	if builder.data != nil {
+		resp.Data = builder.data
+	}
+
+	return Render(builder.ctx, builder.status, resp)
+}
+
+// paginate sets the page counts and links of the response.
+func (builder *ResponseBuilder) paginate(resp *envelope) {
+	pagination := resp.Pagination
+	links := make(map[string]string)
+
+	if pagination.Page != nil && pagination.Size != nil && *pagination.Size > 0 {
+		page := *pagination.Page
+		hasPrev := page > 1
+
+		resp.HasPrev = &hasPrev
+
+		links["self"] = builder.link("page", strconv.Itoa(page))
+		links["first"] = builder.link("page", "1")
+
+		if hasPrev {
+			links["prev"] = builder.link("page", strconv.Itoa(page-1))
+		}
+
+		if pagination.TotalElement != nil {
+			totalPages := (*pagination.TotalElement + *pagination.Size - 1) / *pagination.Size
+			hasNext := page < totalPages
+
+			resp.TotalPages = &totalPages
+			resp.HasNext = &hasNext
+
+			if hasNext {
+				links["next"] = builder.link("page", strconv.Itoa(page+1))
+			}
+
+			if totalPages > 0 {
+				links["last"] = builder.link("page", strconv.Itoa(totalPages))
+			}
+		}
+	}
+
+	if pagination.NextCursor != nil {
+		hasNext := true
+
+		resp.HasNext = &hasNext
+		links["next"] = builder.link("after", *pagination.NextCursor, "before")
+	}
+
+	if pagination.PrevCursor != nil {
+		hasPrev := true
+
+		resp.HasPrev = &hasPrev
+		links["prev"] = builder.link("before", *pagination.PrevCursor, "after")
+	}
+
+	for rel, href := range resp.Links {
+		links[rel] = href
+	}
+
+	if len(links) > 0 {
+		resp.Links = links
+	}
+}
+
+// link returns the request uri with the query param set to the value
+// and the given query params removed.
+func (builder *ResponseBuilder) link(key, value string, remove ...string) string {
+	uri := *builder.ctx.Request().URL
+
+	query := uri.Query()
+	query.Set(key, value)
+
+	for _, key := range remove {
+		query.Del(key)
+	}
+
+	uri.RawQuery = query.Encode()
+
+	return uri.RequestURI()
+}
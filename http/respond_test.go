@@ -0,0 +1,179 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// sendJSON sends the response of the builder for the target
+// and decodes its json body.
+func sendJSON(t *testing.T, target string, build func(*ResponseBuilder) *ResponseBuilder) map[string]any {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+
+	err := build(Respond(echo.New().NewContext(req, rec))).Send()
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var body map[string]any
+
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	return body
+}
+
+func TestSendKeepsPaginationData(t *testing.T) {
+	pagination := NewPagination("", 1, 10)
+	pagination.Data = []string{"a"}
+
+	body := sendJSON(t, "/", func(builder *ResponseBuilder) *ResponseBuilder {
+		return builder.Pagination(pagination)
+	})
+
+	if data, _ := body["data"].([]any); len(data) != 1 || data[0] != "a" {
+		t.Errorf("Send() data = %v, want the data of the pagination", body["data"])
+	}
+
+	body = sendJSON(t, "/", func(builder *ResponseBuilder) *ResponseBuilder {
+		return builder.Pagination(pagination).Data([]string{"b"})
+	})
+
+	if data, _ := body["data"].([]any); len(data) != 1 || data[0] != "b" {
+		t.Errorf("Send() data = %v, want the data of the builder", body["data"])
+	}
+}
+
+func TestSendPageCountsAndLinks(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		pagination  *Pagination
+		wantTotal   any
+		wantHasNext any
+		wantHasPrev any
+		wantLinks   map[string]string
+	}{
+		{
+			name:        "first page",
+			target:      "/users?page=1&size=10",
+			pagination:  &Pagination{Page: intPtr(1), Size: intPtr(10), TotalElement: intPtr(25)},
+			wantTotal:   float64(3),
+			wantHasNext: true,
+			wantHasPrev: false,
+			wantLinks: map[string]string{
+				"self":  "/users?page=1&size=10",
+				"first": "/users?page=1&size=10",
+				"next":  "/users?page=2&size=10",
+				"last":  "/users?page=3&size=10",
+			},
+		},
+		{
+			name:        "last page",
+			target:      "/users?page=3&size=10&q=a",
+			pagination:  &Pagination{Page: intPtr(3), Size: intPtr(10), TotalElement: intPtr(25)},
+			wantTotal:   float64(3),
+			wantHasNext: false,
+			wantHasPrev: true,
+			wantLinks: map[string]string{
+				"self":  "/users?page=3&q=a&size=10",
+				"first": "/users?page=1&q=a&size=10",
+				"prev":  "/users?page=2&q=a&size=10",
+				"last":  "/users?page=3&q=a&size=10",
+			},
+		},
+		{
+			name:        "empty",
+			target:      "/users",
+			pagination:  &Pagination{Page: intPtr(1), Size: intPtr(10), TotalElement: intPtr(0)},
+			wantTotal:   float64(0),
+			wantHasNext: false,
+			wantHasPrev: false,
+			wantLinks: map[string]string{
+				"self":  "/users?page=1",
+				"first": "/users?page=1",
+			},
+		},
+		{
+			name:        "cursors",
+			target:      "/users?after=a&size=10",
+			pagination:  &Pagination{NextCursor: strPtr("b"), PrevCursor: strPtr("c")},
+			wantHasNext: true,
+			wantHasPrev: true,
+			wantLinks: map[string]string{
+				"next": "/users?after=b&size=10",
+				"prev": "/users?before=c&size=10",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := sendJSON(t, test.target, func(builder *ResponseBuilder) *ResponseBuilder {
+				return builder.Pagination(test.pagination)
+			})
+
+			if body["total_pages"] != test.wantTotal || body["has_next"] != test.wantHasNext || body["has_prev"] != test.wantHasPrev {
+				t.Errorf("Send() total_pages, has_next, has_prev = %v, %v, %v, want %v, %v, %v",
+					body["total_pages"], body["has_next"], body["has_prev"], test.wantTotal, test.wantHasNext, test.wantHasPrev)
+			}
+
+			links, _ := body["links"].(map[string]any)
+			if len(links) != len(test.wantLinks) {
+				t.Errorf("Send() links = %v, want %v", links, test.wantLinks)
+			}
+
+			for rel, href := range test.wantLinks {
+				if links[rel] != href {
+					t.Errorf("Send() link %s = %v, want %s", rel, links[rel], href)
+				}
+			}
+		})
+	}
+}
+
+func TestSendKeepsGivenLinks(t *testing.T) {
+	page, size, total := 1, 10, 5
+
+	body := sendJSON(t, "/users", func(builder *ResponseBuilder) *ResponseBuilder {
+		return builder.
+			Pagination(&Pagination{Page: &page, Size: &size, TotalElement: &total}).
+			Links(map[string]string{"self": "/v2/users", "docs": "/docs/users"})
+	})
+
+	links, _ := body["links"].(map[string]any)
+	if links["self"] != "/v2/users" || links["docs"] != "/docs/users" || links["first"] != "/users?page=1" {
+		t.Errorf("Send() links = %v, want the given links over the pagination links", links)
+	}
+}
+
+func TestSendNoContent(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/users/1", nil)
+	rec := httptest.NewRecorder()
+
+	err := Respond(echo.New().NewContext(req, rec)).Status(http.StatusNoContent).Data("ignored").Send()
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Errorf("Send() = %d with %q, want %d without a body", rec.Code, rec.Body.String(), http.StatusNoContent)
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -21,5 +21,5 @@ func Response(ctx echo.Context, code string, data any, pagination *Pagination) e
 		Pagination: pagination,
 	}
 
-	return ctx.JSON(200, resp)
+	return Render(ctx, 200, resp)
 }
@@ -0,0 +1,14 @@
+package common
+
+import (
+	"context"
+	"database/sql"
+)
+
+type (
+	// Execer is implemented by *sql.DB, *sql.Tx and *sql.Conn, and by
+	// ent clients and transactions generated with the sql/execquery feature.
+	Execer interface {
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	}
+)
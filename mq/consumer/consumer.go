@@ -1,7 +1,15 @@
 package consumer
 
+import "context"
+
 type (
 	Consumer interface {
 		Consume(queueName string) error
 	}
+
+	// Shutdowner is implemented by consumers that can be stopped
+	// gracefully, e.g. PostgresConsumer.
+	Shutdowner interface {
+		Shutdown(ctx context.Context) error
+	}
 )
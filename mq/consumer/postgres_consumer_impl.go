@@ -0,0 +1,369 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cetnfurkan/core/mq/common"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	_ Shutdowner = (*PostgresConsumer)(nil)
+)
+
+type (
+	// PostgresJob is a job claimed from the postgres queue.
+	//
+	// The job is completed when the handler returns, unless Fail is called.
+	// A failed job is retried with exponential backoff until its max attempts,
+	// then it is left in the table as dead.
+	PostgresJob struct {
+		ID          int64
+		Queue       string
+		Payload     []byte
+		Headers     map[string]string
+		Priority    int
+		Attempts    int
+		MaxAttempts int
+
+		err error
+	}
+
+	PostgresConsumer struct {
+		db                *sql.DB
+		table             string
+		concurrency       int
+		pollInterval      time.Duration
+		visibilityTimeout time.Duration
+		backoff           time.Duration
+		retention         time.Duration
+		messageHandler    common.MessageHandler[*PostgresJob]
+
+		stop    chan struct{}
+		once    sync.Once
+		workers sync.WaitGroup
+	}
+
+	PostgresConsumerOption func(*PostgresConsumer)
+)
+
+// Fail marks the job as failed with the error, it is retried later.
+func (job *PostgresJob) Fail(err error) {
+	job.err = err
+}
+
+// WithPostgresConcurrency sets the number of jobs processed at
+// the same time per queue. Default is 1.
+func WithPostgresConcurrency(concurrency int) PostgresConsumerOption {
+	return func(consumer *PostgresConsumer) {
+		consumer.concurrency = concurrency
+	}
+}
+
+// WithPostgresPollInterval sets the wait between polls when the queue is empty.
+// Default is 1 second.
+func WithPostgresPollInterval(interval time.Duration) PostgresConsumerOption {
+	return func(consumer *PostgresConsumer) {
+		consumer.pollInterval = interval
+	}
+}
+
+// WithPostgresVisibilityTimeout sets how long a claimed job is hidden from
+// other consumers. A job that is not finished in time is claimed again.
+// Default is 5 minutes.
+func WithPostgresVisibilityTimeout(timeout time.Duration) PostgresConsumerOption {
+	return func(consumer *PostgresConsumer) {
+		consumer.visibilityTimeout = timeout
+	}
+}
+
+// WithPostgresBackoff sets the base delay of the exponential backoff
+// between attempts. Default is 1 second.
+func WithPostgresBackoff(backoff time.Duration) PostgresConsumerOption {
+	return func(consumer *PostgresConsumer) {
+		consumer.backoff = backoff
+	}
+}
+
+// WithPostgresRetention sets how long done jobs are kept before they are
+// deleted. Default is 24 hours, zero disables the cleanup.
+func WithPostgresRetention(retention time.Duration) PostgresConsumerOption {
+	return func(consumer *PostgresConsumer) {
+		consumer.retention = retention
+	}
+}
+
+// NewPostgresConsumer creates a new postgres consumer instance.
+//
+// It takes the pool and the table of the jobs and the handler of the jobs.
+// The consumer implements Shutdowner, assert it to drain the workers, e.g.
+//
+//	consumer.(Shutdowner).Shutdown(ctx)
+func NewPostgresConsumer(
+	db *sql.DB,
+	table string,
+	messageHandler common.MessageHandler[*PostgresJob],
+	opts ...PostgresConsumerOption,
+) Consumer {
+
+	consumer := &PostgresConsumer{
+		db:                db,
+		table:             table,
+		concurrency:       1,
+		pollInterval:      time.Second,
+		visibilityTimeout: 5 * time.Minute,
+		backoff:           time.Second,
+		retention:         24 * time.Hour,
+		messageHandler:    messageHandler,
+		stop:              make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(consumer)
+	}
+
+	return consumer
+}
+
+// Consume processes the jobs of the queue until Shutdown is called.
+// It returns once the jobs in progress are finished.
+func (consumer *PostgresConsumer) Consume(queueName string) error {
+	var workers sync.WaitGroup
+
+	for i := 0; i < consumer.concurrency; i++ {
+		workers.Add(1)
+		consumer.workers.Add(1)
+
+		go func() {
+			defer workers.Done()
+			defer consumer.workers.Done()
+
+			consumer.work(queueName)
+		}()
+	}
+
+	if consumer.retention > 0 {
+		workers.Add(1)
+
+		go func() {
+			defer workers.Done()
+
+			consumer.cleanup(queueName)
+		}()
+	}
+
+	workers.Wait()
+
+	return nil
+}
+
+// Shutdown stops claiming jobs and waits for the jobs in progress.
+// It returns an error if the context is done first.
+func (consumer *PostgresConsumer) Shutdown(ctx context.Context) error {
+	consumer.once.Do(func() {
+		close(consumer.stop)
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		consumer.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (consumer *PostgresConsumer) work(queueName string) {
+	for {
+		select {
+		case <-consumer.stop:
+			return
+
+		default:
+		}
+
+		job, err := consumer.claim(queueName)
+		if err != nil {
+			log.Printf("postgres consumer failed to claim job from %s: %v", queueName, err)
+		}
+
+		if job == nil {
+			select {
+			case <-consumer.stop:
+				return
+
+			case <-time.After(consumer.pollInterval):
+			}
+
+			continue
+		}
+
+		consumer.process(job)
+	}
+}
+
+// claim locks the next runnable job of the queue for the visibility timeout.
+// Jobs whose visibility timeout expired are claimed again.
+func (consumer *PostgresConsumer) claim(queueName string) (*PostgresJob, error) {
+	ctx := context.Background()
+
+	// Jobs abandoned on their last attempt are not retried.
+	_, err := consumer.db.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			`UPDATE %s SET status = 'dead', last_error = 'visibility timeout exceeded', updated_at = now()
+			WHERE queue = $1 AND status = 'running' AND locked_until < now() AND attempts >= max_attempts`,
+			consumer.table,
+		),
+		queueName,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to mark abandoned jobs as dead")
+	}
+
+	var (
+		job     = &PostgresJob{Queue: queueName}
+		headers []byte
+	)
+
+	err = consumer.db.QueryRowContext(
+		ctx,
+		fmt.Sprintf(
+			`UPDATE %[1]s SET status = 'running', attempts = attempts + 1,
+				locked_until = now() + $2 * interval '1 second', updated_at = now()
+			WHERE id = (
+				SELECT id FROM %[1]s
+				WHERE queue = $1 AND (
+					(status = 'pending' AND run_at <= now()) OR
+					(status = 'running' AND locked_until < now())
+				)
+				ORDER BY priority DESC, run_at, id
+				LIMIT 1 FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, payload, headers, priority, attempts, max_attempts`,
+			consumer.table,
+		),
+		queueName,
+		consumer.visibilityTimeout.Seconds(),
+	).Scan(&job.ID, &job.Payload, &headers, &job.Priority, &job.Attempts, &job.MaxAttempts)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(headers, &job.Headers)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode job headers")
+	}
+
+	return job, nil
+}
+
+func (consumer *PostgresConsumer) process(job *PostgresJob) {
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				job.Fail(fmt.Errorf("panic: %v", r))
+			}
+		}()
+
+		consumer.messageHandler(job)
+	}()
+
+	var err error
+
+	if job.err != nil {
+		err = consumer.fail(job)
+	} else {
+		err = consumer.complete(job)
+	}
+
+	if err != nil {
+		log.Printf("postgres consumer failed to update job %d: %v", job.ID, err)
+	}
+}
+
+// complete marks the job as done. The attempts guard against updating
+// a job that was claimed again after its visibility timeout.
+func (consumer *PostgresConsumer) complete(job *PostgresJob) error {
+	_, err := consumer.db.ExecContext(
+		context.Background(),
+		fmt.Sprintf(
+			`UPDATE %s SET status = 'done', locked_until = NULL, finished_at = now(), updated_at = now()
+			WHERE id = $1 AND attempts = $2 AND status = 'running'`,
+			consumer.table,
+		),
+		job.ID,
+		job.Attempts,
+	)
+
+	return err
+}
+
+func (consumer *PostgresConsumer) fail(job *PostgresJob) error {
+	status := "pending"
+	if job.Attempts >= job.MaxAttempts {
+		status = "dead"
+		log.Printf("postgres job %d of %s is dead after %d attempts: %v", job.ID, job.Queue, job.Attempts, job.err)
+	}
+
+	delay := consumer.backoff * time.Duration(1<<min(job.Attempts-1, 16))
+
+	_, err := consumer.db.ExecContext(
+		context.Background(),
+		fmt.Sprintf(
+			`UPDATE %s SET status = $3, last_error = $4, locked_until = NULL,
+				run_at = now() + $5 * interval '1 second', updated_at = now()
+			WHERE id = $1 AND attempts = $2 AND status = 'running'`,
+			consumer.table,
+		),
+		job.ID,
+		job.Attempts,
+		status,
+		job.err.Error(),
+		delay.Seconds(),
+	)
+
+	return err
+}
+
+func (consumer *PostgresConsumer) cleanup(queueName string) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-consumer.stop:
+			return
+
+		case <-ticker.C:
+		}
+
+		_, err := consumer.db.ExecContext(
+			context.Background(),
+			fmt.Sprintf("DELETE FROM %s WHERE queue = $1 AND status = 'done' AND finished_at < now() - $2 * interval '1 second'", consumer.table),
+			queueName,
+			consumer.retention.Seconds(),
+		)
+		if err != nil {
+			log.Printf("postgres consumer failed to clean up %s: %v", queueName, err)
+		}
+	}
+}
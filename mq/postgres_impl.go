@@ -0,0 +1,120 @@
+package mq
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/cetnfurkan/core/database"
+	"github.com/cetnfurkan/core/mq/common"
+	"github.com/cetnfurkan/core/mq/consumer"
+	"github.com/cetnfurkan/core/mq/producer"
+)
+
+const (
+	PostgresJobsTable = "jobs"
+)
+
+// PostgresJobsSchema creates the jobs table with the index used to dequeue by priority.
+var PostgresJobsSchema = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (
+	id           BIGSERIAL PRIMARY KEY,
+	queue        TEXT NOT NULL,
+	payload      BYTEA NOT NULL,
+	headers      JSONB NOT NULL DEFAULT '{}',
+	priority     INT NOT NULL DEFAULT 0,
+	status       TEXT NOT NULL DEFAULT 'pending',
+	attempts     INT NOT NULL DEFAULT 0,
+	max_attempts INT NOT NULL DEFAULT 10,
+	last_error   TEXT,
+	run_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+	locked_until TIMESTAMPTZ,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	finished_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS %[1]s_dequeue_idx ON %[1]s (queue, priority DESC, run_at, id) WHERE status IN ('pending', 'running');`, PostgresJobsTable)
+
+type (
+	// PostgresMQ is a job queue stored in a postgres table.
+	//
+	// Jobs are claimed with FOR UPDATE SKIP LOCKED, so any number of
+	// consumers can share a queue. Delivery is at least once.
+	PostgresMQ struct {
+		db       *sql.DB
+		consumer consumer.Consumer
+		producer producer.Producer
+
+		consumerOptions []consumer.PostgresConsumerOption
+
+		// callbacks
+		consumerMessageHandler common.MessageHandler[*consumer.PostgresJob]
+	}
+
+	postgresOption func(*PostgresMQ)
+)
+
+func WithPostgresConsumerMessageHandler(handler common.MessageHandler[*consumer.PostgresJob]) postgresOption {
+	return func(postgresmq *PostgresMQ) {
+		postgresmq.consumerMessageHandler = handler
+	}
+}
+
+func WithPostgresConsumerOptions(opts ...consumer.PostgresConsumerOption) postgresOption {
+	return func(postgresmq *PostgresMQ) {
+		postgresmq.consumerOptions = append(postgresmq.consumerOptions, opts...)
+	}
+}
+
+// NewPostgresMQ creates a new postgres job queue instance.
+//
+// It takes a database created by NewPostgresDatabase whose pool holds
+// the jobs table, see PostgresJobsSchema. Its consumer implements
+// consumer.Shutdowner and its producer producer.TxProducer.
+//
+// It will panic if the database is not backed by database/sql.
+func NewPostgresMQ(db database.Database, opts ...postgresOption) MQ {
+	pool, err := database.GetDB(db)
+	if err != nil {
+		log.Fatal("failed to get postgres connection pool: ", err)
+	}
+
+	postgresmq := &PostgresMQ{
+		db: pool,
+	}
+
+	postgresmq.consumerMessageHandler = postgresmq.defaultMessageHandler
+
+	for _, opt := range opts {
+		opt(postgresmq)
+	}
+
+	postgresmq.consumer = consumer.NewPostgresConsumer(
+		pool,
+		PostgresJobsTable,
+		postgresmq.consumerMessageHandler,
+		postgresmq.consumerOptions...,
+	)
+
+	postgresmq.producer = producer.NewPostgresProducer(
+		pool,
+		PostgresJobsTable,
+	)
+
+	return postgresmq
+}
+
+func (postgresmq *PostgresMQ) Connection() (any, error) {
+	return postgresmq.db, nil
+}
+
+func (postgresmq *PostgresMQ) Consumer() consumer.Consumer {
+	return postgresmq.consumer
+}
+
+func (postgresmq *PostgresMQ) Producer() producer.Producer {
+	return postgresmq.producer
+}
+
+func (postgresmq *PostgresMQ) defaultMessageHandler(job *consumer.PostgresJob) {
+	fmt.Println(string(job.Payload))
+}
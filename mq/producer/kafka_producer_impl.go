@@ -31,6 +31,10 @@ func NewKafkaProducer(
 }
 
 func (producer *KafkaProducer) Produce(topic string, message []byte) error {
+	return producer.ProduceWithHeaders(topic, message, nil)
+}
+
+func (producer *KafkaProducer) ProduceWithHeaders(topic string, message []byte, headers map[string]string) error {
 
 	producer.mutex.Lock()
 	defer producer.mutex.Unlock()
@@ -54,7 +58,16 @@ func (producer *KafkaProducer) Produce(topic string, message []byte) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	kafkaHeaders := make([]kafka.Header, 0, len(headers))
+	for key, value := range headers {
+		kafkaHeaders = append(kafkaHeaders, kafka.Header{
+			Key:   key,
+			Value: []byte(value),
+		})
+	}
+
 	return writer.WriteMessages(ctx, kafka.Message{
-		Value: message,
+		Value:   message,
+		Headers: kafkaHeaders,
 	})
 }
@@ -0,0 +1,134 @@
+package producer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cetnfurkan/core/mq/common"
+)
+
+var (
+	_ TxProducer     = (*PostgresProducer)(nil)
+	_ HeaderProducer = (*PostgresProducer)(nil)
+)
+
+type (
+	// TxProducer enqueues postgres jobs within a database transaction,
+	// it is implemented by PostgresProducer.
+	TxProducer interface {
+		Enqueue(ctx context.Context, execer common.Execer, queueName string, message []byte, opts ...PostgresJobOption) error
+	}
+
+	PostgresProducer struct {
+		db    *sql.DB
+		table string
+	}
+
+	postgresJob struct {
+		headers     map[string]string
+		priority    int
+		maxAttempts int
+		runAt       time.Time
+	}
+
+	PostgresJobOption func(*postgresJob)
+)
+
+// WithJobHeaders sets the headers of the job.
+func WithJobHeaders(headers map[string]string) PostgresJobOption {
+	return func(job *postgresJob) {
+		job.headers = headers
+	}
+}
+
+// WithJobPriority sets the priority of the job, higher runs first.
+// Default is 0.
+func WithJobPriority(priority int) PostgresJobOption {
+	return func(job *postgresJob) {
+		job.priority = priority
+	}
+}
+
+// WithJobMaxAttempts sets how many times the job is tried before it is dead.
+// Default is 10.
+func WithJobMaxAttempts(attempts int) PostgresJobOption {
+	return func(job *postgresJob) {
+		job.maxAttempts = attempts
+	}
+}
+
+// WithJobRunAt schedules the job to run at the given time.
+func WithJobRunAt(runAt time.Time) PostgresJobOption {
+	return func(job *postgresJob) {
+		job.runAt = runAt
+	}
+}
+
+// WithJobDelay schedules the job to run after the given delay.
+func WithJobDelay(delay time.Duration) PostgresJobOption {
+	return func(job *postgresJob) {
+		job.runAt = time.Now().Add(delay)
+	}
+}
+
+// NewPostgresProducer creates a new postgres producer instance.
+//
+// It takes the pool and the table of the jobs. The producer implements
+// TxProducer, assert it to enqueue jobs within a transaction, e.g.
+//
+//	producer.(TxProducer).Enqueue(ctx, tx, "emails", message)
+func NewPostgresProducer(db *sql.DB, table string) Producer {
+	return &PostgresProducer{
+		db:    db,
+		table: table,
+	}
+}
+
+func (producer *PostgresProducer) Produce(queueName string, message []byte) error {
+	return producer.ProduceWithHeaders(queueName, message, nil)
+}
+
+func (producer *PostgresProducer) ProduceWithHeaders(queueName string, message []byte, headers map[string]string) error {
+	return producer.Enqueue(context.Background(), producer.db, queueName, message, WithJobHeaders(headers))
+}
+
+// Enqueue writes a job into the queue. When execer is a transaction,
+// the job is visible to the consumers once the transaction is committed.
+func (producer *PostgresProducer) Enqueue(ctx context.Context, execer common.Execer, queueName string, message []byte, opts ...PostgresJobOption) error {
+	job := &postgresJob{
+		maxAttempts: 10,
+		runAt:       time.Now(),
+	}
+
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	if job.headers == nil {
+		job.headers = map[string]string{}
+	}
+
+	headers, err := json.Marshal(job.headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = execer.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			"INSERT INTO %s (queue, payload, headers, priority, max_attempts, run_at) VALUES ($1, $2, $3, $4, $5, $6)",
+			producer.table,
+		),
+		queueName,
+		message,
+		headers,
+		job.priority,
+		job.maxAttempts,
+		job.runAt,
+	)
+
+	return err
+}
@@ -4,4 +4,10 @@ type (
 	Producer interface {
 		Produce(queueName string, message []byte) error
 	}
+
+	// HeaderProducer is implemented by producers that can attach
+	// headers to a message.
+	HeaderProducer interface {
+		ProduceWithHeaders(queueName string, message []byte, headers map[string]string) error
+	}
 )
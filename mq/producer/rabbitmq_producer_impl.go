@@ -52,6 +52,10 @@ func NewRabbitMQProducer(
 }
 
 func (producer *RabbitMQProducer) Produce(queueName string, message []byte) error {
+	return producer.ProduceWithHeaders(queueName, message, nil)
+}
+
+func (producer *RabbitMQProducer) ProduceWithHeaders(queueName string, message []byte, headers map[string]string) error {
 
 	producer.mutex.Lock()
 	defer producer.mutex.Unlock()
@@ -80,6 +84,11 @@ func (producer *RabbitMQProducer) Produce(queueName string, message []byte) erro
 		return err
 	}
 
+	amqpHeaders := make(amqp.Table, len(headers))
+	for key, value := range headers {
+		amqpHeaders[key] = value
+	}
+
 	err = channel.Publish(
 		"",         // exchange
 		queue.Name, // routing key
@@ -87,6 +96,7 @@ func (producer *RabbitMQProducer) Produce(queueName string, message []byte) erro
 		false,      // immediate
 		amqp.Publishing{
 			ContentType: "application/json",
+			Headers:     amqpHeaders,
 			Body:        message,
 			Expiration:  producer.expiration,
 		},
@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cetnfurkan/core/database"
+	coreErrors "github.com/cetnfurkan/core/errors"
+)
+
+const (
+	Table = "outbox"
+)
+
+// Schema creates the outbox table that Enqueue fills and a Relay drains.
+var Schema = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (
+	id           BIGSERIAL PRIMARY KEY,
+	topic        TEXT NOT NULL,
+	payload      BYTEA NOT NULL,
+	headers      JSONB NOT NULL DEFAULT '{}',
+	attempts     INT NOT NULL DEFAULT 0,
+	last_error   TEXT,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	available_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	sent_at      TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS %[1]s_pending_idx ON %[1]s (available_at) WHERE sent_at IS NULL;`, Table)
+
+// Enqueue writes a message into the outbox table within the given transaction.
+// The message is published by a Relay once the transaction is committed.
+//
+// tx can be a *sql.Tx or an ent transaction generated with the
+// sql/execquery feature.
+func Enqueue(ctx context.Context, tx database.Execer, topic string, payload []byte, headers map[string]string) error {
+	if headers == nil {
+		headers = map[string]string{}
+	}
+
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		fmt.Sprintf("INSERT INTO %s (topic, payload, headers) VALUES ($1, $2, $3)", Table),
+		topic,
+		payload,
+		encodedHeaders,
+	)
+
+	return err
+}
+
+// AuditSink returns an audit sink for database.AuditCallback that enqueues
+// the audit entries as JSON to the topic in the transaction of the mutation.
+// The ent client must be generated with the sql/execquery feature.
+func AuditSink(topic string) database.AuditSink {
+	return database.AuditSinkFunc(func(ctx context.Context, execer database.Execer, entry *database.AuditEntry) error {
+		if execer == nil {
+			return coreErrors.ErrExecQueryNotSupported
+		}
+
+		payload, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		return Enqueue(ctx, execer, topic, payload, nil)
+	})
+}
@@ -0,0 +1,289 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cetnfurkan/core/mq/producer"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// Relay publishes the messages written by Enqueue through a producer.
+	//
+	// Several relays can run against the same table, rows are claimed
+	// with FOR UPDATE SKIP LOCKED so each message is published by one relay.
+	Relay struct {
+		db              *sql.DB
+		producer        producer.Producer
+		batchSize       int
+		pollInterval    time.Duration
+		maxAttempts     int
+		backoff         time.Duration
+		retention       time.Duration
+		cleanupInterval time.Duration
+	}
+
+	relayOption func(*Relay)
+
+	message struct {
+		id       int64
+		topic    string
+		payload  []byte
+		headers  map[string]string
+		attempts int
+	}
+)
+
+// WithRelayBatchSize sets the number of messages claimed per poll.
+// Default is 100.
+func WithRelayBatchSize(size int) relayOption {
+	return func(relay *Relay) {
+		relay.batchSize = size
+	}
+}
+
+// WithRelayPollInterval sets the wait between polls when the outbox is empty.
+// Default is 1 second.
+func WithRelayPollInterval(interval time.Duration) relayOption {
+	return func(relay *Relay) {
+		relay.pollInterval = interval
+	}
+}
+
+// WithRelayMaxAttempts sets how many times a message is tried before it is
+// left in the table as dead. Default is 10.
+func WithRelayMaxAttempts(attempts int) relayOption {
+	return func(relay *Relay) {
+		relay.maxAttempts = attempts
+	}
+}
+
+// WithRelayBackoff sets the base delay of the exponential backoff
+// between attempts. Default is 1 second.
+func WithRelayBackoff(backoff time.Duration) relayOption {
+	return func(relay *Relay) {
+		relay.backoff = backoff
+	}
+}
+
+// WithRelayRetention sets how long delivered messages are kept before
+// they are deleted. Default is 24 hours, zero disables the cleanup.
+func WithRelayRetention(retention time.Duration) relayOption {
+	return func(relay *Relay) {
+		relay.retention = retention
+	}
+}
+
+// NewRelay creates a new outbox relay instance.
+//
+// It takes the postgres pool that holds the outbox table and the producer
+// the messages are published with. Message headers are only published
+// if the producer implements producer.HeaderProducer.
+func NewRelay(db *sql.DB, producer producer.Producer, opts ...relayOption) *Relay {
+	relay := &Relay{
+		db:              db,
+		producer:        producer,
+		batchSize:       100,
+		pollInterval:    time.Second,
+		maxAttempts:     10,
+		backoff:         time.Second,
+		retention:       24 * time.Hour,
+		cleanupInterval: time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(relay)
+	}
+
+	return relay
+}
+
+// Start polls the outbox and publishes the messages until the context is done.
+func (relay *Relay) Start(ctx context.Context) error {
+	lastCleanup := time.Time{}
+
+	for {
+		published, flushErr := relay.Flush(ctx)
+		if flushErr != nil {
+			log.Printf("outbox relay failed to flush: %v", flushErr)
+		}
+
+		if relay.retention > 0 && time.Since(lastCleanup) >= relay.cleanupInterval {
+			err := relay.Cleanup(ctx)
+			if err != nil {
+				log.Printf("outbox relay failed to clean up: %v", err)
+			}
+
+			lastCleanup = time.Now()
+		}
+
+		// Keep draining while full batches are found.
+		if flushErr == nil && published == relay.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-time.After(relay.pollInterval):
+		}
+	}
+}
+
+// Flush claims a batch of pending messages and publishes them.
+// It returns the number of claimed messages.
+//
+// The claimed rows stay locked in one transaction while the batch is
+// published, so a slow producer holds a connection and the row locks
+// for the whole batch, use WithRelayBatchSize to bound it. Messages are
+// published at least once: if the transaction fails to commit, they are
+// published again by a later poll.
+//
+// Messages are published in order within a batch, and once a message of
+// a topic fails the remaining messages of that topic in the batch are
+// left for the next poll. The order is not kept beyond that: the failed
+// message is retried after its backoff, while later messages of its topic
+// can be published by the next polls or by other relays.
+func (relay *Relay) Flush(ctx context.Context) (int, error) {
+	tx, err := relay.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	messages, err := relay.claim(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	failedTopics := make(map[string]bool)
+
+	for _, message := range messages {
+		if failedTopics[message.topic] {
+			continue
+		}
+
+		err = relay.publish(message)
+		if err != nil {
+			failedTopics[message.topic] = true
+
+			err = relay.markFailed(ctx, tx, message, err)
+			if err != nil {
+				return 0, err
+			}
+
+			continue
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			fmt.Sprintf("UPDATE %s SET sent_at = now(), attempts = attempts + 1 WHERE id = $1", Table),
+			message.id,
+		)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to mark outbox message as sent")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(messages), nil
+}
+
+// Cleanup deletes the messages delivered before the retention period.
+func (relay *Relay) Cleanup(ctx context.Context) error {
+	_, err := relay.db.ExecContext(
+		ctx,
+		fmt.Sprintf("DELETE FROM %s WHERE sent_at IS NOT NULL AND sent_at < now() - $1 * interval '1 second'", Table),
+		relay.retention.Seconds(),
+	)
+
+	return err
+}
+
+func (relay *Relay) claim(ctx context.Context, tx *sql.Tx) ([]*message, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			`SELECT id, topic, payload, headers, attempts FROM %s
+			WHERE sent_at IS NULL AND available_at <= now() AND attempts < $1
+			ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED`,
+			Table,
+		),
+		relay.maxAttempts,
+		relay.batchSize,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim outbox messages")
+	}
+
+	defer rows.Close()
+
+	var messages []*message
+	for rows.Next() {
+		var (
+			msg     = &message{}
+			headers []byte
+		)
+
+		err = rows.Scan(&msg.id, &msg.topic, &msg.payload, &headers, &msg.attempts)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan outbox message")
+		}
+
+		err = json.Unmarshal(headers, &msg.headers)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode outbox message headers")
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+func (relay *Relay) publish(message *message) error {
+	headerProducer, ok := relay.producer.(producer.HeaderProducer)
+	if ok && len(message.headers) > 0 {
+		return headerProducer.ProduceWithHeaders(message.topic, message.payload, message.headers)
+	}
+
+	return relay.producer.Produce(message.topic, message.payload)
+}
+
+func (relay *Relay) markFailed(ctx context.Context, tx *sql.Tx, message *message, cause error) error {
+	attempts := message.attempts + 1
+	delay := relay.backoff * time.Duration(1<<min(attempts-1, 16))
+
+	if attempts >= relay.maxAttempts {
+		log.Printf("outbox message %d to %s is dead after %d attempts: %v", message.id, message.topic, attempts, cause)
+	}
+
+	_, err := tx.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			"UPDATE %s SET attempts = $2, last_error = $3, available_at = now() + $4 * interval '1 second' WHERE id = $1",
+			Table,
+		),
+		message.id,
+		attempts,
+		cause.Error(),
+		delay.Seconds(),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to mark outbox message as failed")
+	}
+
+	return nil
+}
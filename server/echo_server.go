@@ -5,7 +5,10 @@ import (
 	"log"
 
 	"github.com/cetnfurkan/core/config"
+	coreErrors "github.com/cetnfurkan/core/errors"
+	"github.com/cetnfurkan/core/http"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
@@ -40,6 +43,38 @@ func WithLogger(logger echo.Logger) echoServerOption {
 	}
 }
 
+// WithErrorHandler replaces the default error handler. The service name
+// and debug mode of the config are used unless they are given.
+func WithErrorHandler(opts ...coreErrors.ErrorHandlerOption) echoServerOption {
+	return func(server *echoServer) error {
+		server.app.HTTPErrorHandler = coreErrors.HTTPErrorHandler(append(server.errorHandlerOptions(), opts...)...)
+		return nil
+	}
+}
+
+// WithErrorCatalogController serves the error catalog of the registry
+// at the path, e.g. /admin/errors. The default registry is used when
+// registry is nil.
+func WithErrorCatalogController(path string, registry *coreErrors.Registry) echoServerOption {
+	return func(server *echoServer) error {
+		if registry == nil {
+			registry = coreErrors.DefaultRegistry
+		}
+
+		server.app.GET(path, registry.Handler())
+		return nil
+	}
+}
+
+// WithValidator replaces the default validator with one having the
+// given custom rules, see http.NewValidator.
+func WithValidator(opts ...http.ValidatorOption) echoServerOption {
+	return func(server *echoServer) error {
+		server.app.Validator = http.NewValidator(opts...)
+		return nil
+	}
+}
+
 func WithSwaggerController() echoServerOption {
 	return func(server *echoServer) error {
 		server.app.GET("/docs/*", echoSwagger.WrapHandler)
@@ -52,13 +87,23 @@ func WithSwaggerController() echoServerOption {
 // It takes a config instance, a database instance and a cache instance
 // and returns a new server interface instance.
 //
+// Errors returned from the handlers and recovered panics are written
+// by errors.HTTPErrorHandler, see WithErrorHandler. Requests are
+// validated by http.Validator, see WithValidator and http.Bind.
+//
 // It will panic if it fails to create a new echo server instance.
 func NewEchoServer(cfg *config.Server, opts ...echoServerOption) Server {
-	return &echoServer{
+	server := &echoServer{
 		app:     echo.New(),
 		cfg:     cfg,
 		options: opts,
 	}
+
+	server.app.HTTPErrorHandler = coreErrors.HTTPErrorHandler(server.errorHandlerOptions()...)
+	server.app.Validator = http.NewValidator()
+	server.app.Use(middleware.Recover())
+
+	return server
 }
 
 func (server *echoServer) Start() {
@@ -80,6 +125,13 @@ func (server *echoServer) start() {
 	server.app.Logger.Fatal(server.app.Start(serverUrl))
 }
 
+func (server *echoServer) errorHandlerOptions() []coreErrors.ErrorHandlerOption {
+	return []coreErrors.ErrorHandlerOption{
+		coreErrors.WithHandlerServiceName(server.cfg.Name),
+		coreErrors.WithHandlerDebug(server.cfg.Debug),
+	}
+}
+
 func (server *echoServer) Stop() error {
 	return server.app.Close()
 }
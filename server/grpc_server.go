@@ -3,9 +3,11 @@ package server
 import (
 	"context"
 	_ "embed"
+	"fmt"
 	"time"
 
 	"github.com/cetnfurkan/core/config"
+	coreErrors "github.com/cetnfurkan/core/errors"
 	"github.com/labstack/gommon/log"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
@@ -17,6 +19,7 @@ import (
 
 var (
 	_ grpc.UnaryServerInterceptor = UnaryLogHandler
+	_ grpc.UnaryServerInterceptor = UnaryErrorHandler
 )
 
 type (
@@ -77,8 +80,11 @@ func NewGRPCServer(cfg *config.Server, name string, boot []byte, opts ...grpcSer
 
 	server.entry.ServerOpts = append(
 		server.entry.ServerOpts,
+		// The error handler wraps the log handler, so the original
+		// errors are logged with their cause chain and stack trace.
 		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
 			grpc_ctxtags.UnaryServerInterceptor(),
+			UnaryErrorHandler,
 			UnaryLogHandler,
 		)),
 	)
@@ -118,10 +124,29 @@ func UnaryLogHandler(ctx context.Context, req interface{}, info *grpc.UnaryServe
 	ip, _ := tags["ip"].(string)
 
 	if err != nil {
-		log.Errorf("[ERROR] %s took: %s ip: %s err: %s req: %s", info.FullMethod, latency, ip, err, req)
+		fields := coreErrors.LogFields(err)
+		fields["method"] = info.FullMethod
+		fields["took"] = latency.String()
+		fields["ip"] = ip
+		fields["req"] = fmt.Sprint(req)
+
+		log.Errorj(fields)
 	} else {
 		log.Infof("[INFO] %s took: %s ip: %s req: %s", info.FullMethod, latency, ip, req)
 	}
 
 	return
 }
+
+// UnaryErrorHandler converts the errors returned by the handlers to gRPC
+// statuses, so app errors are sent with their mapped codes and
+// other errors do not leak to the clients. Messages are localized
+// for the accept-language metadata of the request.
+func UnaryErrorHandler(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return resp, coreErrors.GrpcStatusContext(ctx, err).Err()
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,134 @@
+package tenant
+
+import (
+	"context"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	DefaultHeader      = "X-Tenant-ID"
+	DefaultMetadataKey = "x-tenant-id"
+)
+
+type (
+	contextKey struct{}
+
+	// Validator checks whether the caller of the context may act for the
+	// tenant, e.g. against the principal set by the authentication.
+	Validator func(ctx context.Context, tenant string) error
+
+	options struct {
+		validator Validator
+	}
+
+	option func(*options)
+)
+
+// WithValidator validates the tenant before it is set on the context.
+// Rejected requests fail with errors.ErrTenantForbidden and the error
+// of the validator as its cause.
+func WithValidator(validator Validator) option {
+	return func(opts *options) {
+		opts.validator = validator
+	}
+}
+
+// NewContext returns a context that carries the tenant.
+func NewContext(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+// FromContext returns the tenant carried by the context.
+func FromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(contextKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// EchoMiddleware sets the tenant of the request context from the header.
+// DefaultHeader is used if header is empty.
+//
+// Requests without the header are passed on without a tenant.
+//
+// The header is sent by the client, so without WithValidator it is only
+// safe behind a gateway that authenticates the client and sets the header.
+// The middleware must run after the authentication the validator relies on.
+func EchoMiddleware(header string, opts ...option) echo.MiddlewareFunc {
+	if header == "" {
+		header = DefaultHeader
+	}
+
+	tenantOpts := newOptions(opts)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenant := c.Request().Header.Get(header)
+			if tenant != "" {
+				ctx, err := tenantOpts.newContext(c.Request().Context(), tenant)
+				if err != nil {
+					return err
+				}
+
+				c.SetRequest(c.Request().WithContext(ctx))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// UnaryServerInterceptor sets the tenant of the call context from the
+// incoming metadata. DefaultMetadataKey is used if key is empty.
+//
+// Calls without the metadata are passed on without a tenant.
+//
+// The metadata is sent by the client, so without WithValidator it is only
+// safe behind a gateway that authenticates the client and sets the metadata.
+// The interceptor must run after the authentication the validator relies on.
+func UnaryServerInterceptor(key string, opts ...option) grpc.UnaryServerInterceptor {
+	if key == "" {
+		key = DefaultMetadataKey
+	}
+
+	tenantOpts := newOptions(opts)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		values := metadata.ValueFromIncomingContext(ctx, key)
+		if len(values) > 0 && values[0] != "" {
+			var err error
+
+			ctx, err = tenantOpts.newContext(ctx, values[0])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func newOptions(opts []option) *options {
+	tenantOpts := &options{}
+
+	for _, opt := range opts {
+		opt(tenantOpts)
+	}
+
+	return tenantOpts
+}
+
+// newContext validates the tenant and returns a context that carries it.
+func (opts *options) newContext(ctx context.Context, tenant string) (context.Context, error) {
+	if opts.validator != nil {
+		err := opts.validator(ctx, tenant)
+		if err != nil {
+			return nil, coreErrors.ErrTenantForbidden.Wrap(err)
+		}
+	}
+
+	return NewContext(ctx, tenant), nil
+}
@@ -0,0 +1,150 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	coreErrors "github.com/cetnfurkan/core/errors"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	errNotMember = errors.New("not a member")
+)
+
+// memberValidator allows the tenants named "acme".
+func memberValidator(ctx context.Context, tenant string) error {
+	if tenant != "acme" {
+		return errNotMember
+	}
+
+	return nil
+}
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("FromContext() ok = true for a context without tenant")
+	}
+
+	if _, ok := FromContext(NewContext(context.Background(), "")); ok {
+		t.Error("FromContext() ok = true for an empty tenant")
+	}
+
+	tenant, ok := FromContext(NewContext(context.Background(), "acme"))
+	if !ok || tenant != "acme" {
+		t.Errorf("FromContext() = %q, %v, want %q, true", tenant, ok, "acme")
+	}
+}
+
+func TestEchoMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		opts       []option
+		wantTenant string
+		wantErr    error
+	}{
+		{name: "default header", header: DefaultHeader, value: "acme", wantTenant: "acme"},
+		{name: "missing header", header: DefaultHeader},
+		{name: "other header", header: "X-Other", value: "acme"},
+		{name: "validated", header: DefaultHeader, value: "acme", opts: []option{WithValidator(memberValidator)}, wantTenant: "acme"},
+		{name: "rejected", header: DefaultHeader, value: "evil", opts: []option{WithValidator(memberValidator)}, wantErr: coreErrors.ErrTenantForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.value != "" {
+				req.Header.Set(test.header, test.value)
+			}
+
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			var (
+				called bool
+				tenant string
+			)
+
+			err := EchoMiddleware("", test.opts...)(func(c echo.Context) error {
+				called = true
+				tenant, _ = FromContext(c.Request().Context())
+				return nil
+			})(c)
+
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) || !errors.Is(err, errNotMember) {
+					t.Fatalf("EchoMiddleware() error = %v, want %v caused by %v", err, test.wantErr, errNotMember)
+				}
+
+				if called {
+					t.Error("EchoMiddleware() called the handler of a rejected tenant")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("EchoMiddleware() error = %v", err)
+			}
+
+			if tenant != test.wantTenant {
+				t.Errorf("EchoMiddleware() tenant = %q, want %q", tenant, test.wantTenant)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name       string
+		md         metadata.MD
+		opts       []option
+		wantTenant string
+		wantErr    error
+	}{
+		{name: "default key", md: metadata.Pairs(DefaultMetadataKey, "acme"), wantTenant: "acme"},
+		{name: "missing metadata"},
+		{name: "empty value", md: metadata.Pairs(DefaultMetadataKey, "")},
+		{name: "validated", md: metadata.Pairs(DefaultMetadataKey, "acme"), opts: []option{WithValidator(memberValidator)}, wantTenant: "acme"},
+		{name: "rejected", md: metadata.Pairs(DefaultMetadataKey, "evil"), opts: []option{WithValidator(memberValidator)}, wantErr: coreErrors.ErrTenantForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, test.md)
+			}
+
+			var tenant string
+
+			_, err := UnaryServerInterceptor("", test.opts...)(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+				tenant, _ = FromContext(ctx)
+				return nil, nil
+			})
+
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("UnaryServerInterceptor() error = %v, want %v", err, test.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("UnaryServerInterceptor() error = %v", err)
+			}
+
+			if tenant != test.wantTenant {
+				t.Errorf("UnaryServerInterceptor() tenant = %q, want %q", tenant, test.wantTenant)
+			}
+		})
+	}
+}